@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Bake samples the animation at a fixed frame rate into one timestamp per
+// frame, which normalises clips authored with uneven timestamp spacing. When
+// the duration is not a whole number of frames the frames are spread a
+// little so the last one still lands on the end of the clip and loops keep
+// their length.
+func Bake(anim Animation, fps int) Animation {
+	duration := anim.duration()
+	frames := int(math.Round(float64(duration) * float64(fps)))
+	if frames == 0 && duration > 0 {
+		frames = 1
+	}
+	step := 1.0 / float32(fps)
+	if frames > 0 {
+		step = duration / float32(frames)
+	}
+
+	baked := Animation{anim.StartTime, step, make([]AnimationTimeStamp, 0, frames+1)}
+	for frame := 0; frame <= frames; frame++ {
+		time := mgl32.Clamp(float32(frame)*step, 0, duration)
+		if frame == frames {
+			time = duration
+		}
+		baked.TimeStamps = append(baked.TimeStamps, AnimationTimeStamp{frame, anim.sample(time)})
+	}
+
+	return baked
+}
+
+// ReduceKeyframes drops the timestamps that linear interpolation between the
+// remaining ones reproduces within the given tolerances. The position
+// tolerance applies to translations and scales, the rotation one in radians.
+func ReduceKeyframes(anim Animation, posTolerance, rotTolerance float32) Animation {
+	reduced := Animation{anim.StartTime, anim.TimeStampDuration, make([]AnimationTimeStamp, 0)}
+	if len(anim.TimeStamps) <= 2 {
+		reduced.TimeStamps = append(reduced.TimeStamps, anim.TimeStamps...)
+		return reduced
+	}
+
+	anchor := 0
+	reduced.TimeStamps = append(reduced.TimeStamps, anim.TimeStamps[0])
+	for end := 2; end < len(anim.TimeStamps); end++ {
+		if !interpolates(anim.TimeStamps, anchor, end, posTolerance, rotTolerance) {
+			anchor = end - 1
+			reduced.TimeStamps = append(reduced.TimeStamps, anim.TimeStamps[anchor])
+		}
+	}
+	reduced.TimeStamps = append(reduced.TimeStamps, anim.TimeStamps[len(anim.TimeStamps)-1])
+
+	return reduced
+}
+
+// reports whether every timestamp strictly between from and to is close
+// enough to the interpolation of the two
+func interpolates(timestamps []AnimationTimeStamp, from, to int, posTolerance, rotTolerance float32) bool {
+	start := timestamps[from]
+	end := timestamps[to]
+
+	for _, ts := range timestamps[from+1 : to] {
+		factor := float32(ts.TimePoint-start.TimePoint) / float32(end.TimePoint-start.TimePoint)
+
+		for i, trans := range ts.Translations {
+			translation := vec3Lerp(start.Translations[i].Translation, end.Translations[i].Translation, factor)
+			rotationY := lerp(start.Translations[i].RotationY, end.Translations[i].RotationY, factor)
+			scale := vec3Lerp(start.Translations[i].Scale, end.Translations[i].Scale, factor)
+			rotation := mgl32.QuatSlerp(start.Translations[i].Rotation, end.Translations[i].Rotation, factor)
+
+			if mgl32.Vec3(translation).Sub(trans.Translation).Len() > posTolerance ||
+				mgl32.Abs(rotationY-trans.RotationY) > rotTolerance ||
+				quatDistance(rotation, trans.Rotation) > rotTolerance ||
+				mgl32.Vec3(scale).Sub(trans.Scale).Len() > posTolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestBakeKeepsDuration(t *testing.T) {
+	// 1.3 seconds, not a whole number of frames at 24 fps
+	anim := LoadAnimation("resources/animations/sidestep.saf")
+	anim.TimeStampDuration = 1.3 / float32(anim.TimeStamps[len(anim.TimeStamps)-1].TimePoint)
+
+	for _, fps := range []int{24, 30, 60} {
+		baked := Bake(anim, fps)
+		if d := baked.duration(); mgl32.Abs(d-anim.duration()) > 1e-5 {
+			t.Errorf("%d fps: baked clip lasts %vs, want %vs", fps, d, anim.duration())
+		}
+
+		last := baked.TimeStamps[len(baked.TimeStamps)-1].Translations[0]
+		want := anim.TimeStamps[len(anim.TimeStamps)-1].Translations[0]
+		if mgl32.Vec3(last.Translation).Sub(want.Translation).Len() > 1e-5 {
+			t.Errorf("%d fps: last frame at %v, want %v", fps, last.Translation, want.Translation)
+		}
+	}
+}
+
+// a node moving along X through the given positions, one timestamp each
+func slidingClip(xs ...float32) Animation {
+	anim := Animation{0.0, 1.0, make([]AnimationTimeStamp, len(xs))}
+	for i, x := range xs {
+		key := restKey(0)
+		key.Translation = [3]float32{x, 0.0, 0.0}
+		anim.TimeStamps[i] = AnimationTimeStamp{i, []NodeAnimationTranslation{key}}
+	}
+	return anim
+}
+
+func timePoints(anim Animation) []int {
+	points := make([]int, len(anim.TimeStamps))
+	for i, ts := range anim.TimeStamps {
+		points[i] = ts.TimePoint
+	}
+	return points
+}
+
+func TestReduceKeyframesDropsLinearKeys(t *testing.T) {
+	reduced := ReduceKeyframes(slidingClip(0.0, 1.0, 2.0, 3.0, 4.0), 1e-3, 1e-3)
+	if points := timePoints(reduced); len(points) != 2 || points[0] != 0 || points[1] != 4 {
+		t.Errorf("kept time points %v, want only 0 and 4", points)
+	}
+
+	// a still clip keeps its first and last keys, so the length stays
+	reduced = ReduceKeyframes(slidingClip(1.0, 1.0, 1.0), 1e-3, 1e-3)
+	if points := timePoints(reduced); len(points) != 2 || points[0] != 0 || points[1] != 2 {
+		t.Errorf("kept time points %v of a still clip, want 0 and 2", points)
+	}
+	reduced = ReduceKeyframes(slidingClip(1.0, 2.0), 1e-3, 1e-3)
+	if points := timePoints(reduced); len(points) != 2 {
+		t.Errorf("kept time points %v of a two key clip", points)
+	}
+}
+
+func TestReduceKeyframesKeepsKeysPastTolerance(t *testing.T) {
+	// each channel ramps up to time point 2 and then holds, so only the key
+	// at the kink is needed besides the first and last ones
+	bumps := map[string]func(key *NodeAnimationTranslation, f float32){
+		"translation": func(key *NodeAnimationTranslation, f float32) { key.Translation[1] = 0.01 * f },
+		"rotation Y":  func(key *NodeAnimationTranslation, f float32) { key.RotationY = 0.01 * f },
+		"rotation": func(key *NodeAnimationTranslation, f float32) {
+			key.Rotation = mgl32.QuatRotate(0.01*f, mgl32.Vec3{1.0, 0.0, 0.0})
+		},
+		"scale": func(key *NodeAnimationTranslation, f float32) { key.Scale[2] = 1.0 + 0.01*f },
+	}
+
+	for name, bump := range bumps {
+		anim := slidingClip(0.0, 0.0, 0.0, 0.0, 0.0)
+		for i, f := range []float32{0.0, 0.5, 1.0, 1.0, 1.0} {
+			bump(&anim.TimeStamps[i].Translations[0], f)
+		}
+
+		reduced := ReduceKeyframes(anim, 1e-3, 1e-3)
+		if points := timePoints(reduced); len(points) != 3 || points[1] != 2 {
+			t.Errorf("%s: kept time points %v, want 0, 2 and 4", name, points)
+		}
+
+		// within a looser tolerance the kink goes as well
+		if points := timePoints(ReduceKeyframes(anim, 0.1, 0.1)); len(points) != 2 {
+			t.Errorf("%s: kept time points %v with a loose tolerance", name, points)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type bvhJoint struct {
+	node     int
+	channels []string
+	// the OFFSET of the joint, which position channels replace
+	offset mgl32.Vec3
+}
+
+type bvhParser struct {
+	words  []string
+	pos    int
+	tree   AnimationTree
+	joints []bvhJoint
+}
+
+// LoadBVH builds an animation tree from the HIERARCHY section of a motion
+// capture file and an animation from its MOTION section. Every frame becomes
+// a time point and rotations are kept in the order of their channels. Joint
+// offsets add up to the node positions and End Site entries become nodes
+// named after their joint with an "End" suffix.
+//
+// Position channels replace the OFFSET of their joint, as in the BVH files
+// of most tools: the root's are absolute positions and the others are
+// relative to the parent joint, in the parent's rotated frame. Keys store
+// the difference to the rest position either way.
+func LoadBVH(filename string) (AnimationTree, Animation, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return AnimationTree{}, Animation{}, fmt.Errorf("motion capture %q not found on disk: %v", filename, err)
+	}
+	defer file.Close()
+
+	p := bvhParser{tree: AnimationTree{make([]*AnimationNode, 0), make([]SkinVertex, 0)}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		p.words = append(p.words, strings.Fields(scanner.Text())...)
+	}
+	if err := scanner.Err(); err != nil {
+		return AnimationTree{}, Animation{}, err
+	}
+
+	if err := p.expect("HIERARCHY"); err != nil {
+		return AnimationTree{}, Animation{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	if err := p.expect("ROOT"); err != nil {
+		return AnimationTree{}, Animation{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	if err := p.parseJoint(-1); err != nil {
+		return AnimationTree{}, Animation{}, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	anim, err := p.parseMotion()
+	if err != nil {
+		return AnimationTree{}, Animation{}, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return p.tree, anim, nil
+}
+
+func (p *bvhParser) next() (string, error) {
+	if (*p).pos >= len((*p).words) {
+		return "", fmt.Errorf("unexpected end of file")
+	}
+	word := (*p).words[(*p).pos]
+	(*p).pos++
+	return word, nil
+}
+
+func (p *bvhParser) expect(words ...string) error {
+	for _, want := range words {
+		word, err := p.next()
+		if err != nil {
+			return err
+		}
+		if word != want {
+			return fmt.Errorf("expected %q, found %q", want, word)
+		}
+	}
+	return nil
+}
+
+func (p *bvhParser) floats(n int) ([]float32, error) {
+	values := make([]float32, n)
+	for i := range values {
+		word, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseFloat(word, 32)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float32(v)
+	}
+	return values, nil
+}
+
+// parses the body of a ROOT or JOINT, starting with its name; End Site
+// entries become nodes without channels
+func (p *bvhParser) parseJoint(parent int) error {
+	name, err := p.next()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("{", "OFFSET"); err != nil {
+		return err
+	}
+	return p.parseNode(name, parent, true)
+}
+
+func (p *bvhParser) parseNode(name string, parent int, hasChannels bool) error {
+	offset, err := p.floats(3)
+	if err != nil {
+		return err
+	}
+
+	pos := mgl32.Vec3{offset[0], offset[1], offset[2]}
+	if parent >= 0 {
+		pos = pos.Add((*p).tree.Nodes[parent].Pos)
+	}
+
+	node := NewAnimationNode(pos)
+	node.Name = name
+	if parent >= 0 {
+		(*p).tree.Nodes[parent].Children = append((*p).tree.Nodes[parent].Children, &node)
+	}
+	idx := len((*p).tree.Nodes)
+	(*p).tree.Nodes = append((*p).tree.Nodes, &node)
+
+	if hasChannels {
+		if err := p.expect("CHANNELS"); err != nil {
+			return err
+		}
+		word, err := p.next()
+		if err != nil {
+			return err
+		}
+		count, err := strconv.Atoi(word)
+		if err != nil {
+			return err
+		}
+
+		joint := bvhJoint{idx, make([]string, count), mgl32.Vec3{offset[0], offset[1], offset[2]}}
+		for i := range joint.channels {
+			if joint.channels[i], err = p.next(); err != nil {
+				return err
+			}
+		}
+		(*p).joints = append((*p).joints, joint)
+	}
+
+	for {
+		word, err := p.next()
+		if err != nil {
+			return err
+		}
+
+		switch word {
+		case "}":
+			return nil
+		case "JOINT":
+			if err := p.parseJoint(idx); err != nil {
+				return err
+			}
+		case "End":
+			if err := p.expect("Site", "{", "OFFSET"); err != nil {
+				return err
+			}
+			if err := p.parseNode(name+"End", idx, false); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected %q in joint %s", word, name)
+		}
+	}
+}
+
+func (p *bvhParser) parseMotion() (Animation, error) {
+	if err := p.expect("MOTION", "Frames:"); err != nil {
+		return Animation{}, err
+	}
+	word, err := p.next()
+	if err != nil {
+		return Animation{}, err
+	}
+	frames, err := strconv.Atoi(word)
+	if err != nil {
+		return Animation{}, err
+	}
+
+	if err := p.expect("Frame", "Time:"); err != nil {
+		return Animation{}, err
+	}
+	frameTime, err := p.floats(1)
+	if err != nil {
+		return Animation{}, err
+	}
+
+	anim := Animation{0.0, frameTime[0], make([]AnimationTimeStamp, 0, frames)}
+	for frame := 0; frame < frames; frame++ {
+		timestamp := AnimationTimeStamp{frame, make([]NodeAnimationTranslation, 0, len((*p).joints))}
+		// rotation of every joint relative to the rest pose, parents first
+		globals := make(map[int]mgl32.Quat)
+
+		for _, joint := range (*p).joints {
+			values, err := p.floats(len(joint.channels))
+			if err != nil {
+				return Animation{}, fmt.Errorf("frame %d: %v", frame, err)
+			}
+
+			key := NodeAnimationTranslation{
+				NodeIdx:  joint.node,
+				Scale:    [3]float32{1.0, 1.0, 1.0},
+				Rotation: mgl32.QuatIdent()}
+
+			position := joint.offset
+			for c, channel := range joint.channels {
+				switch channel {
+				case "Xposition", "Yposition", "Zposition":
+					position[channel[0]-'X'] = values[c]
+				case "Xrotation", "Yrotation", "Zrotation":
+					axis := mgl32.Vec3{}
+					axis[channel[0]-'X'] = 1.0
+					// channels are listed in the order the rotations are applied
+					key.Rotation = key.Rotation.Mul(mgl32.QuatRotate(mgl32.DegToRad(values[c]), axis))
+				default:
+					return Animation{}, fmt.Errorf("unknown channel %q", channel)
+				}
+			}
+			key.Rotation = key.Rotation.Normalize()
+
+			parent, ok := globals[(*p).tree.parentOf(joint.node)]
+			if !ok {
+				parent = mgl32.QuatIdent()
+			}
+			globals[joint.node] = parent.Mul(key.Rotation)
+			key.Translation = parent.Rotate(position.Sub(joint.offset))
+
+			timestamp.Translations = append(timestamp.Translations, key)
+		}
+
+		anim.TimeStamps = append(anim.TimeStamps, timestamp)
+	}
+
+	return anim, nil
+}
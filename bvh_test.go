@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func writeBVH(t *testing.T, lines ...string) string {
+	filename := filepath.Join(t.TempDir(), "test.bvh")
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadBVHWave(t *testing.T) {
+	tree, anim, err := LoadBVH("resources/mocap/wave.bvh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []struct {
+		name   string
+		parent int
+		pos    [3]float32
+	}{
+		{"hips", -1, [3]float32{0.0, 0.0, 0.0}},
+		{"arm", 0, [3]float32{0.0, 1.0, 0.0}},
+		{"hand", 1, [3]float32{0.0, 2.0, 0.0}},
+		{"handEnd", 2, [3]float32{0.0, 2.5, 0.0}},
+	}
+	if len(tree.Nodes) != len(nodes) {
+		t.Fatalf("%d nodes, want %d", len(tree.Nodes), len(nodes))
+	}
+	for i, want := range nodes {
+		node := tree.Nodes[i]
+		if node.Name != want.name || tree.parentOf(i) != want.parent || node.Pos != want.pos {
+			t.Errorf("node %d is %s under %d at %v, want %s under %d at %v",
+				i, node.Name, tree.parentOf(i), node.Pos, want.name, want.parent, want.pos)
+		}
+	}
+
+	if anim.TimeStampDuration != 0.25 || len(anim.TimeStamps) != 5 || anim.duration() != 1.0 {
+		t.Errorf("%d frames of %vs, want 5 of 0.25s", len(anim.TimeStamps), anim.TimeStampDuration)
+	}
+	// the end site has no channels and so no keys
+	for _, key := range anim.TimeStamps[0].Translations {
+		if key.NodeIdx == 3 {
+			t.Error("end site keyed")
+		}
+	}
+
+	// halfway the arm is raised 90 degrees about Z and the hand 60 more,
+	// so the end site points 150 degrees away from up
+	tree = anim.animate(tree, 0.5)
+	if hand := tree.Nodes[2].worldPosition(); hand.Sub(mgl32.Vec3{-1.0, 1.0, 0.0}).Len() > 1e-4 {
+		t.Errorf("hand at %v, want (-1, 1, 0)", hand)
+	}
+	want := mgl32.Vec3{-1.0 - 0.5*0.5, 1.0 - 0.5*0.866025, 0.0}
+	if end := tree.Nodes[3].worldPosition(); end.Sub(want).Len() > 1e-4 {
+		t.Errorf("end site at %v, want %v", end, want)
+	}
+}
+
+func TestLoadBVHRotationOrder(t *testing.T) {
+	load := func(channels string, values string) mgl32.Quat {
+		_, anim, err := LoadBVH(writeBVH(t,
+			"HIERARCHY", "ROOT hips", "{", "OFFSET 0 0 0", "CHANNELS 3 "+channels,
+			"End Site", "{", "OFFSET 0 1 0", "}", "}",
+			"MOTION", "Frames: 1", "Frame Time: 0.1", values))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return anim.TimeStamps[0].Translations[0].Rotation
+	}
+
+	// 30 about X, 40 about Y and 50 about Z, listed in two orders
+	zxy := load("Zrotation Xrotation Yrotation", "50 30 40")
+	xyz := load("Xrotation Yrotation Zrotation", "30 40 50")
+
+	rx := mgl32.QuatRotate(mgl32.DegToRad(30), mgl32.Vec3{1.0, 0.0, 0.0})
+	ry := mgl32.QuatRotate(mgl32.DegToRad(40), mgl32.Vec3{0.0, 1.0, 0.0})
+	rz := mgl32.QuatRotate(mgl32.DegToRad(50), mgl32.Vec3{0.0, 0.0, 1.0})
+	if !zxy.OrientationEqualThreshold(rz.Mul(rx).Mul(ry), 1e-5) {
+		t.Errorf("ZXY gives %v, want %v", zxy, rz.Mul(rx).Mul(ry))
+	}
+	if !xyz.OrientationEqualThreshold(rx.Mul(ry).Mul(rz), 1e-5) {
+		t.Errorf("XYZ gives %v, want %v", xyz, rx.Mul(ry).Mul(rz))
+	}
+	if zxy.OrientationEqualThreshold(xyz, 1e-3) {
+		t.Error("ZXY and XYZ give the same rotation")
+	}
+}
+
+func TestLoadBVHPositionChannels(t *testing.T) {
+	tree, anim, err := LoadBVH(writeBVH(t,
+		"HIERARCHY", "ROOT hips", "{", "OFFSET 1 2 3",
+		"CHANNELS 6 Xposition Yposition Zposition Zrotation Xrotation Yrotation",
+		"JOINT tail", "{", "OFFSET 0 1 0", "CHANNELS 3 Xposition Yposition Zposition",
+		"End Site", "{", "OFFSET 0 1 0", "}", "}", "}",
+		"MOTION", "Frames: 1", "Frame Time: 0.1",
+		"1 2.5 3 90 0 0 0 2 0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the root position is absolute, so its offset is not added again
+	if key := anim.TimeStamps[0].Translations[0]; key.Translation != [3]float32{0.0, 0.5, 0.0} {
+		t.Errorf("root keyed at %v, want the 0.5 it moved", key.Translation)
+	}
+
+	// the tail moves two units from the hips instead of one, along the
+	// hips turned 90 degrees about Z
+	tree = anim.animate(tree, 0.0)
+	if hips := tree.Nodes[0].worldPosition(); hips.Sub(mgl32.Vec3{1.0, 2.5, 3.0}).Len() > 1e-4 {
+		t.Errorf("hips at %v, want (1, 2.5, 3)", hips)
+	}
+	if tail := tree.Nodes[1].worldPosition(); tail.Sub(mgl32.Vec3{-1.0, 2.5, 3.0}).Len() > 1e-4 {
+		t.Errorf("tail at %v, want (-1, 2.5, 3)", tail)
+	}
+}
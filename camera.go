@@ -0,0 +1,257 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type CameraMode int
+
+const (
+	// the camera circles Target at Distance
+	CameraOrbit CameraMode = iota
+	// the camera moves freely from Position
+	CameraFly
+)
+
+// pitch stays short of straight up or down, where the view would flip
+const maxPitch = 89.0 * math.Pi / 180.0
+
+// the furthest the orbit camera zooms out
+const maxCameraDistance = 100.0
+
+// Camera is an interactive view of the scene. Yaw and Pitch give the
+// direction from the look-at point towards the eye, so both modes see the
+// same picture and switching between them keeps the view.
+type Camera struct {
+	Mode CameraMode
+
+	Target   mgl32.Vec3
+	Distance float32
+	// eye position in fly mode
+	Position mgl32.Vec3
+	// radians, yaw around the Y axis from +Z, pitch up from the XZ plane
+	Yaw   float32
+	Pitch float32
+
+	// fly speed in units per second
+	Speed float32
+	// radians turned per pixel of mouse movement
+	Sensitivity float32
+
+	// mouse state between input callbacks
+	dragging mgl32.Vec2
+	button   glfw.MouseButton
+	held     bool
+}
+
+// NewCamera creates an orbit camera at eye looking at target
+func NewCamera(eye, target mgl32.Vec3) Camera {
+	offset := eye.Sub(target)
+	distance := offset.Len()
+
+	c := Camera{
+		Mode:        CameraOrbit,
+		Target:      target,
+		Distance:    distance,
+		Position:    eye,
+		Speed:       2.0,
+		Sensitivity: 0.005,
+	}
+	if distance > 0 {
+		c.Yaw = float32(math.Atan2(float64(offset[0]), float64(offset[2])))
+		c.Pitch = float32(math.Asin(float64(offset[1] / distance)))
+	}
+	return c
+}
+
+// unit vector from the look-at point towards the eye
+func (c Camera) backward() mgl32.Vec3 {
+	cosPitch := float32(math.Cos(float64(c.Pitch)))
+	return mgl32.Vec3{
+		cosPitch * float32(math.Sin(float64(c.Yaw))),
+		float32(math.Sin(float64(c.Pitch))),
+		cosPitch * float32(math.Cos(float64(c.Yaw)))}
+}
+
+func (c Camera) forward() mgl32.Vec3 {
+	return c.backward().Mul(-1)
+}
+
+func (c Camera) right() mgl32.Vec3 {
+	return c.forward().Cross(mgl32.Vec3{0, 1, 0}).Normalize()
+}
+
+func (c Camera) up() mgl32.Vec3 {
+	return c.right().Cross(c.forward())
+}
+
+func (c Camera) eye() mgl32.Vec3 {
+	if c.Mode == CameraFly {
+		return c.Position
+	}
+	return c.Target.Add(c.backward().Mul(c.Distance))
+}
+
+// the camera matrix of the view
+func (c Camera) view() mgl32.Mat4 {
+	eye := c.eye()
+	return mgl32.LookAtV(eye, eye.Add(c.forward()), mgl32.Vec3{0, 1, 0})
+}
+
+// switches mode without moving the view
+func (c *Camera) setMode(mode CameraMode) {
+	if mode == (*c).Mode {
+		return
+	}
+	if mode == CameraFly {
+		(*c).Position = c.eye()
+	} else {
+		(*c).Target = (*c).Position.Add(c.forward().Mul((*c).Distance))
+	}
+	(*c).Mode = mode
+}
+
+func (c *Camera) toggleMode() {
+	if (*c).Mode == CameraOrbit {
+		c.setMode(CameraFly)
+	} else {
+		c.setMode(CameraOrbit)
+	}
+}
+
+// turns the view by a mouse movement in pixels; orbiting moves the eye
+// around the target, flying turns the head
+func (c *Camera) rotate(dx, dy float32) {
+	(*c).Yaw -= dx * (*c).Sensitivity
+	(*c).Pitch += dy * (*c).Sensitivity
+	if (*c).Pitch > maxPitch {
+		(*c).Pitch = maxPitch
+	}
+	if (*c).Pitch < -maxPitch {
+		(*c).Pitch = -maxPitch
+	}
+}
+
+// moves the orbit target in the view plane by a mouse movement in pixels,
+// faster the further away it is
+func (c *Camera) pan(dx, dy float32) {
+	step := (*c).Distance * 0.002
+	(*c).Target = (*c).Target.Sub(c.right().Mul(dx * step)).Add(c.up().Mul(dy * step))
+}
+
+// zooms towards the target by scroll steps, or flies forward in fly mode
+func (c *Camera) zoom(steps float32) {
+	if (*c).Mode == CameraFly {
+		(*c).Position = (*c).Position.Add(c.forward().Mul(steps * 0.25))
+		return
+	}
+	(*c).Distance *= float32(math.Pow(0.9, float64(steps)))
+	(*c).Distance = mgl32.Clamp((*c).Distance, 0.1, maxCameraDistance)
+}
+
+// moves the eye in fly mode, along the view for forward and sideways for
+// right, and along the Y axis for up
+func (c *Camera) move(forward, right, up float32) {
+	(*c).Position = (*c).Position.
+		Add(c.forward().Mul(forward)).
+		Add(c.right().Mul(right)).
+		Add(mgl32.Vec3{0, up, 0})
+}
+
+// looks at the bounds of the tree's joints from the current direction,
+// close enough for them to fill a view with the given vertical field of
+// view in radians and aspect ratio
+func (c *Camera) frame(tree AnimationTree, fovY, aspect float32) {
+	if len(tree.Nodes) == 0 {
+		return
+	}
+
+	joints, _ := skeletonLines(tree)
+	lo, hi := joints[0], joints[0]
+	for _, joint := range joints[1:] {
+		for i := 0; i < 3; i++ {
+			lo[i] = float32(math.Min(float64(lo[i]), float64(joint[i])))
+			hi[i] = float32(math.Max(float64(hi[i]), float64(joint[i])))
+		}
+	}
+
+	center := lo.Add(hi).Mul(0.5)
+	// joints are points, leave room for the mesh around them
+	radius := hi.Sub(lo).Len()*0.5 + 0.5
+
+	fov := fovY
+	if aspect < 1 {
+		fov = 2 * float32(math.Atan(math.Tan(float64(fovY)/2)*float64(aspect)))
+	}
+	distance := radius / float32(math.Sin(float64(fov)/2))
+
+	(*c).Target = center
+	(*c).Distance = distance
+	(*c).Position = center.Add(c.backward().Mul(distance))
+}
+
+// routes the mouse callbacks of the window to the camera: the left button
+// drags to rotate, the middle button to pan and the wheel zooms
+func (c *Camera) bindInput(window *glfw.Window) {
+	window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		switch action {
+		case glfw.Press:
+			x, y := w.GetCursorPos()
+			(*c).dragging = mgl32.Vec2{float32(x), float32(y)}
+			(*c).button = button
+			(*c).held = true
+		case glfw.Release:
+			if button == (*c).button {
+				(*c).held = false
+			}
+		}
+	})
+
+	window.SetCursorPosCallback(func(w *glfw.Window, x, y float64) {
+		if !(*c).held {
+			return
+		}
+		position := mgl32.Vec2{float32(x), float32(y)}
+		delta := position.Sub((*c).dragging)
+		(*c).dragging = position
+
+		switch {
+		case (*c).button == glfw.MouseButtonMiddle && (*c).Mode == CameraOrbit:
+			c.pan(delta[0], delta[1])
+		case (*c).button == glfw.MouseButtonLeft:
+			c.rotate(delta[0], delta[1])
+		}
+	})
+
+	window.SetScrollCallback(func(w *glfw.Window, xoff, yoff float64) {
+		c.zoom(float32(yoff))
+	})
+}
+
+// moves a flying camera with WASD, Q and E for the time elapsed since the
+// last frame; shift flies faster
+func (c *Camera) update(window *glfw.Window, elapsed float64) {
+	if (*c).Mode != CameraFly {
+		return
+	}
+
+	step := (*c).Speed * float32(elapsed)
+	if window.GetKey(glfw.KeyLeftShift) == glfw.Press {
+		step *= 4
+	}
+
+	axis := func(positive, negative glfw.Key) float32 {
+		var v float32
+		if window.GetKey(positive) == glfw.Press {
+			v++
+		}
+		if window.GetKey(negative) == glfw.Press {
+			v--
+		}
+		return v
+	}
+	c.move(axis(glfw.KeyW, glfw.KeyS)*step, axis(glfw.KeyD, glfw.KeyA)*step, axis(glfw.KeyE, glfw.KeyQ)*step)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestCameraFrameKeepsSkeletonInView(t *testing.T) {
+	for _, skeleton := range []string{"resources/skeletons/cube.sks", "resources/skeletons/cube_rig.sks"} {
+		tree, _, err := LoadSkeleton(skeleton)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anim := LoadAnimation("resources/animations/jump.saf")
+		tree = anim.animate(tree, anim.StartTime+float64(anim.duration())/2)
+		joints, _ := skeletonLines(tree)
+
+		for _, size := range [][2]int{{800, 600}, {300, 600}} {
+			camera := NewCamera(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0})
+			camera.rotate(120, -40)
+			camera.frame(tree, mgl32.DegToRad(fieldOfView), float32(size[0])/float32(size[1]))
+
+			clip := perspective(size[0], size[1]).Mul4(camera.view())
+			for i, joint := range joints {
+				p := clip.Mul4x1(joint.Vec4(1.0))
+				ndc := p.Vec3().Mul(1 / p[3])
+				if p[3] <= 0 || mgl32.Abs(ndc[0]) > 1 || mgl32.Abs(ndc[1]) > 1 || mgl32.Abs(ndc[2]) > 1 {
+					t.Errorf("%s at %v: joint %d %v is outside the view at %v", skeleton, size, i, joint, ndc)
+				}
+			}
+		}
+		tree.resetTree()
+	}
+}
+
+func TestCameraModeSwitchKeepsEye(t *testing.T) {
+	camera := NewCamera(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 1, 0})
+	camera.rotate(50, 20)
+	eye, view := camera.eye(), camera.view()
+
+	camera.toggleMode()
+	if camera.Mode != CameraFly || !camera.eye().ApproxEqualThreshold(eye, 1e-5) || !camera.view().ApproxEqualThreshold(view, 1e-5) {
+		t.Fatalf("flying from %v, want the orbit eye %v", camera.eye(), eye)
+	}
+
+	camera.move(1.0, 0.5, 0.25)
+	camera.rotate(-30, 10)
+	eye, view = camera.eye(), camera.view()
+	camera.toggleMode()
+	if camera.Mode != CameraOrbit || !camera.eye().ApproxEqualThreshold(eye, 1e-5) || !camera.view().ApproxEqualThreshold(view, 1e-5) {
+		t.Errorf("orbiting from %v, want the flying eye %v", camera.eye(), eye)
+	}
+}
+
+func TestCameraPitchClamp(t *testing.T) {
+	camera := NewCamera(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0})
+	for _, dy := range []float32{1e4, -1e4} {
+		camera.rotate(0, dy)
+		if mgl32.Abs(camera.Pitch) != float32(maxPitch) {
+			t.Errorf("pitch %v after dragging %v pixels, want ±%v", camera.Pitch, dy, float32(maxPitch))
+		}
+		for _, v := range camera.view() {
+			if v != v {
+				t.Fatalf("view %v is not a number looking straight up or down", camera.view())
+			}
+		}
+	}
+}
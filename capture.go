@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// captureTimes returns the times of the frames of a clip played for the
+// given duration at fps; a duration of 0 plays the clip once
+func captureTimes(anim Animation, fps, duration float64) []float64 {
+	if duration <= 0 {
+		duration = float64(anim.duration())
+	}
+
+	count := int(math.Ceil(duration*fps - 1e-9))
+	if count < 1 {
+		count = 1
+	}
+	times := make([]float64, count)
+	for i := range times {
+		times[i] = float64(i) / fps
+	}
+	return times
+}
+
+// SavePNGSequence writes the frames as frame_0000.png, frame_0001.png, ...
+// into dir, creating it when needed
+func SavePNGSequence(dir string, frames []*image.NRGBA) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i, img := range frames {
+		if err := SavePNG(filepath.Join(dir, fmt.Sprintf("frame_%04d.png", i)), img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveGIF writes the frames as a looping animated GIF played at fps. All
+// frames share one palette of at most 256 colors, picked by median cut from
+// the colors of every frame so the background does not flicker; dithering
+// spreads the quantization error with Floyd-Steinberg.
+func SaveGIF(filename string, frames []*image.NRGBA, fps float64, dither bool) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to write to %s", filename)
+	}
+
+	palette := medianCutPalette(frames, 256)
+	var drawer draw.Drawer = draw.Src
+	if dither {
+		drawer = draw.FloydSteinberg
+	}
+
+	anim := &gif.GIF{
+		Image:     make([]*image.Paletted, len(frames)),
+		Delay:     gifDelays(len(frames), fps),
+		LoopCount: 0,
+	}
+	for i, img := range frames {
+		paletted := image.NewPaletted(img.Bounds(), palette)
+		drawer.Draw(paletted, img.Bounds(), img, img.Bounds().Min)
+		anim.Image[i] = paletted
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := gif.EncodeAll(file, anim); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// returns the delays of count frames played at fps, in the hundredths of a
+// second GIF uses; rounding them one by one would make long clips drift, so
+// every delay is the step between the rounded start times of two frames
+func gifDelays(count int, fps float64) []int {
+	delays := make([]int, count)
+	for i := range delays {
+		delays[i] = int(math.Round(float64(i+1)*100/fps) - math.Round(float64(i)*100/fps))
+	}
+	return delays
+}
+
+// a color and the number of pixels that have it
+type colorBucket struct {
+	rgb   [3]uint8
+	count int
+}
+
+type colorBox struct {
+	buckets []colorBucket
+}
+
+func (b colorBox) pixels() int {
+	n := 0
+	for _, bucket := range b.buckets {
+		n += bucket.count
+	}
+	return n
+}
+
+// returns the channel with the widest range of values
+func (b colorBox) widestChannel() int {
+	channel, width := 0, -1
+	for c := 0; c < 3; c++ {
+		lo, hi := 255, 0
+		for _, bucket := range b.buckets {
+			if v := int(bucket.rgb[c]); v < lo {
+				lo = v
+			}
+			if v := int(bucket.rgb[c]); v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > width {
+			channel, width = c, hi-lo
+		}
+	}
+	return channel
+}
+
+// splits the box at the median pixel along its widest channel
+func (b colorBox) split() (colorBox, colorBox) {
+	channel := b.widestChannel()
+	sort.Slice(b.buckets, func(i, j int) bool {
+		return b.buckets[i].rgb[channel] < b.buckets[j].rgb[channel]
+	})
+
+	half := b.pixels() / 2
+	n, at := 0, 1
+	for i, bucket := range b.buckets[:len(b.buckets)-1] {
+		n += bucket.count
+		at = i + 1
+		if n >= half {
+			break
+		}
+	}
+	return colorBox{b.buckets[:at]}, colorBox{b.buckets[at:]}
+}
+
+// the average color of the pixels in the box
+func (b colorBox) average() color.Color {
+	var sum [3]int
+	for _, bucket := range b.buckets {
+		for c := 0; c < 3; c++ {
+			sum[c] += int(bucket.rgb[c]) * bucket.count
+		}
+	}
+	n := b.pixels()
+	return color.RGBA{uint8((sum[0] + n/2) / n), uint8((sum[1] + n/2) / n), uint8((sum[2] + n/2) / n), 255}
+}
+
+// picks up to size colors representing the pixels of all the frames
+func medianCutPalette(frames []*image.NRGBA, size int) color.Palette {
+	histogram := make(map[[3]uint8]int)
+	for _, img := range frames {
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			histogram[[3]uint8{img.Pix[i], img.Pix[i+1], img.Pix[i+2]}]++
+		}
+	}
+
+	buckets := make([]colorBucket, 0, len(histogram))
+	for rgb, count := range histogram {
+		buckets = append(buckets, colorBucket{rgb, count})
+	}
+	if len(buckets) == 0 {
+		// frames without pixels, a GIF palette still needs a color
+		return color.Palette{color.RGBA{0, 0, 0, 255}}
+	}
+
+	boxes := []colorBox{{buckets}}
+	for len(boxes) < size {
+		// split the box with the most pixels among those with more than one color
+		best := -1
+		for i, box := range boxes {
+			if len(box.buckets) > 1 && (best < 0 || box.pixels() > boxes[best].pixels()) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		a, b := boxes[best].split()
+		boxes[best] = a
+		boxes = append(boxes, b)
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		palette[i] = box.average()
+	}
+	return palette
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCaptureTimesFractionalDuration(t *testing.T) {
+	// 0.7s at 30 fps is 21 frames, even though 0.7 is not exact in float32
+	anim := slidingClip(0.0, 1.0, 2.0)
+	anim.TimeStampDuration = 0.35
+	if times := captureTimes(anim, 30, 0); len(times) != 21 {
+		t.Errorf("%d frames for 0.7s at 30 fps, want 21", len(times))
+	}
+
+	// a partial last frame still gets captured
+	times := captureTimes(anim, 25, 0.5+0.01)
+	if len(times) != 13 || times[12] != 12.0/25.0 {
+		t.Errorf("frames %v for 0.51s at 25 fps, want 13 ending at 0.48s", times)
+	}
+}
+
+func TestMedianCutPaletteKeepsFewColors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	colors := []color.NRGBA{{255, 0, 0, 255}, {0, 128, 0, 255}, {10, 20, 30, 255}}
+	for i := 0; i < 16; i++ {
+		img.SetNRGBA(i%4, i/4, colors[i%len(colors)])
+	}
+
+	palette := medianCutPalette([]*image.NRGBA{img}, 256)
+	if len(palette) != len(colors) {
+		t.Fatalf("%d palette colors, want %d", len(palette), len(colors))
+	}
+	for _, c := range colors {
+		r, g, b, _ := palette.Convert(c).RGBA()
+		if uint8(r>>8) != c.R || uint8(g>>8) != c.G || uint8(b>>8) != c.B {
+			t.Errorf("%v is drawn as %v", c, palette.Convert(c))
+		}
+	}
+}
+
+func TestMedianCutPaletteCapsColors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 4), uint8(y * 4), uint8(x + y), 255})
+		}
+	}
+	if palette := medianCutPalette([]*image.NRGBA{img}, 256); len(palette) != 256 {
+		t.Errorf("%d palette colors, want 256", len(palette))
+	}
+
+	empty := image.NewNRGBA(image.Rect(0, 0, 0, 0))
+	if palette := medianCutPalette([]*image.NRGBA{empty, empty}, 256); len(palette) != 1 {
+		t.Errorf("%d palette colors for empty frames, want 1", len(palette))
+	}
+}
+
+func TestGIFDelaysDoNotDrift(t *testing.T) {
+	for _, fps := range []float64{24, 25, 30, 60} {
+		frames := int(60 * fps)
+		sum := 0
+		for _, delay := range gifDelays(frames, fps) {
+			if delay < 1 {
+				t.Fatalf("delay %d at %g fps", delay, fps)
+			}
+			sum += delay
+		}
+		// a minute of frames lasts a minute
+		if sum != 6000 {
+			t.Errorf("%d frames at %g fps last %d hundredths, want 6000", frames, fps, sum)
+		}
+	}
+}
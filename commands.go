@@ -0,0 +1,471 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"view":     {"view [-assets dir] [-renderer gl|software] [-mesh file] [-skeleton file] [-clip file]... [-texture file] [-vs file] [-fs file]", viewCommand},
+	"bvh":      {"bvh <in.bvh> <out.sks> <out.saf>", bvhCommand},
+	"gltf":     {"gltf [-fps n] <in.gltf|in.glb> <out.sks> <clip dir>", gltfCommand},
+	"export":   {"export [-assets dir] [-fps n] [-skeleton file] [-mesh file] <out.glb> <clip.saf>...", exportCommand},
+	"render":   {"render [-assets dir] [-renderer gl|software] [-width n] [-height n] [-skeleton file] [-mesh file] [-out dir] [-frames n] [-fps n] <clip.saf> [time...]", renderCommand},
+	"capture":  {"capture [-assets dir] [-renderer gl|software] [-width n] [-height n] [-fps n] [-duration s] [-dither] [-skeleton file] [-mesh file] <clip.saf> <out.gif|out dir>", captureCommand},
+	"golden":   {"golden [-assets dir] [-renderer gl|software] [-update] [-golden dir] [-diff dir] [-threshold t] [-max-mismatch f]", goldenCommand},
+	"compress": {"compress <in.saf> <out.safb>", compressCommand},
+	"bake":     {"bake [-fps n] [-reduce] [-pos-tolerance d] [-rot-tolerance r] <in.saf> <out.saf>", bakeCommand},
+	"mirror":   {"mirror [-assets dir] [-skeleton file] [-axis x|y|z] <in.saf> <out.saf>", mirrorCommand},
+	"retarget": {"retarget [-map file] <source.sks> <target.sks> <in.saf> <out.saf>", retargetCommand},
+}
+
+func runCommand(args []string) {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintf(os.Stderr, "unknown command %q, available commands:\n", args[0])
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+		}
+		os.Exit(2)
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		log.Fatalf("%s: %v", args[0], err)
+	}
+}
+
+// a flag that can be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// adds the -assets flag that relative resource paths are resolved against
+func assetsFlag(flags *flag.FlagSet) *string {
+	return flags.String("assets", defaultAssetRoot(), "asset root the other paths are relative to, also set by "+assetsEnv)
+}
+
+func viewCommand(args []string) error {
+	flags := flag.NewFlagSet("view", flag.ExitOnError)
+	root := assetsFlag(flags)
+	backend := flags.String("renderer", "gl", "gl opens a window, software plays in the terminal without OpenGL")
+	opts := ViewerOptions{}
+	flags.StringVar(&opts.Mesh, "mesh", "resources/models/cube.obj", "mesh skinned to the skeleton")
+	flags.StringVar(&opts.Skeleton, "skeleton", "resources/skeletons/cube.sks", "skeleton the clips animate")
+	var clips stringList
+	flags.Var(&clips, "clip", "clip to play, repeat for more; every clip of resources/animations by default")
+	flags.StringVar(&opts.Texture, "texture", "resources/textures/square.png", "texture of meshes without materials")
+	flags.StringVar(&opts.VertexShader, "vs", "shaders/test.vs", "vertex shader of the mesh")
+	flags.StringVar(&opts.FragmentShader, "fs", "shaders/test.fs", "fragment shader of the mesh")
+	flags.StringVar(&opts.OverlayVertexShader, "overlay-vs", "shaders/skeleton.vs", "vertex shader of the skeleton and timeline")
+	flags.StringVar(&opts.OverlayFragmentShader, "overlay-fs", "shaders/skeleton.fs", "fragment shader of the skeleton and timeline")
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	opts.Clips = clips
+	opts = opts.resolve(*root)
+	if len(opts.Clips) == 0 {
+		found, err := filepath.Glob(filepath.Join(assetPath(*root, "resources/animations"), "*.saf"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(found)
+		opts.Clips = found
+	}
+
+	switch *backend {
+	case "gl":
+		return RunViewer(opts)
+	case "software":
+		return RunTerminalViewer(opts, os.Stdout, terminalColumns, terminalRows)
+	}
+	return fmt.Errorf("unknown renderer %q, expected one of %v", *backend, offscreenBackends)
+}
+
+func mirrorCommand(args []string) error {
+	flags := flag.NewFlagSet("mirror", flag.ExitOnError)
+	root := assetsFlag(flags)
+	skeleton := flags.String("skeleton", "resources/skeletons/cube.sks", "skeleton used to pair left and right nodes")
+	axis := flags.String("axis", "x", "axis negated by the mirror plane")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("expected an input and an output animation")
+	}
+
+	axes := map[string]MirrorAxis{"x": MirrorX, "y": MirrorY, "z": MirrorZ}
+	mirrorAxis, ok := axes[*axis]
+	if !ok {
+		return fmt.Errorf("unknown axis %q", *axis)
+	}
+
+	tree, _, err := LoadSkeleton(assetPath(*root, *skeleton))
+	if err != nil {
+		return err
+	}
+
+	anim := LoadAnimation(flags.Arg(0))
+	return SaveAnimation(flags.Arg(1), Mirror(anim, tree, mirrorAxis))
+}
+
+func retargetCommand(args []string) error {
+	flags := flag.NewFlagSet("retarget", flag.ExitOnError)
+	mapFile := flags.String("map", "", "file pairing source and target nodes, nodes are paired by name without it")
+	flags.Parse(args)
+
+	if flags.NArg() != 4 {
+		return fmt.Errorf("expected a source and a target skeleton, an input and an output animation")
+	}
+
+	src, _, err := LoadSkeleton(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	dst, _, err := LoadSkeleton(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	nodes := MapNodesByName(src, dst)
+	if *mapFile != "" {
+		nodes, err = LoadNodeMap(*mapFile, src, dst)
+		if err != nil {
+			return err
+		}
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes of %s map to %s", flags.Arg(0), flags.Arg(1))
+	}
+
+	anim := LoadAnimation(flags.Arg(2))
+	if err := SaveAnimation(flags.Arg(3), Retarget(anim, src, dst, nodes)); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d of %d nodes mapped\n", len(nodes), len(src.Nodes))
+	return nil
+}
+
+func bakeCommand(args []string) error {
+	flags := flag.NewFlagSet("bake", flag.ExitOnError)
+	fps := flags.Int("fps", 30, "frames sampled per second, stretched slightly so a whole number of frames spans the clip")
+	reduce := flags.Bool("reduce", false, "drop the frames that interpolation reproduces")
+	posTolerance := flags.Float64("pos-tolerance", 0.001, "allowed translation and scale error when reducing")
+	rotTolerance := flags.Float64("rot-tolerance", 0.001, "allowed rotation error in radians when reducing")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("expected an input and an output animation")
+	}
+	if *fps <= 0 {
+		return fmt.Errorf("fps should be positive, got %d", *fps)
+	}
+
+	anim := Bake(LoadAnimation(flags.Arg(0)), *fps)
+	if *reduce {
+		anim = ReduceKeyframes(anim, float32(*posTolerance), float32(*rotTolerance))
+	}
+
+	return SaveAnimation(flags.Arg(1), anim)
+}
+
+func compressCommand(args []string) error {
+	flags := flag.NewFlagSet("compress", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("expected a text and a binary animation")
+	}
+
+	anim := LoadAnimation(flags.Arg(0))
+	if err := SaveBinaryAnimation(flags.Arg(1), anim); err != nil {
+		return err
+	}
+
+	compressed, err := LoadBinaryAnimation(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	textInfo, err := os.Stat(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	binaryInfo, err := os.Stat(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	posErr, rotErr := animationError(anim, compressed)
+	fmt.Printf("%s: %d -> %d bytes, ratio %.2f\n", flags.Arg(1), textInfo.Size(), binaryInfo.Size(),
+		float64(textInfo.Size())/float64(binaryInfo.Size()))
+	fmt.Printf("max translation/scale error %g, max rotation error %g rad\n", posErr, rotErr)
+
+	return nil
+}
+
+func bvhCommand(args []string) error {
+	flags := flag.NewFlagSet("bvh", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() != 3 {
+		return fmt.Errorf("expected a motion capture file, a skeleton and an animation")
+	}
+
+	tree, anim, err := LoadBVH(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if err := SaveSkeleton(flags.Arg(1), tree, nil); err != nil {
+		return err
+	}
+	return SaveAnimation(flags.Arg(2), anim)
+}
+
+func gltfCommand(args []string) error {
+	flags := flag.NewFlagSet("gltf", flag.ExitOnError)
+	fps := flags.Int("fps", 30, "frames sampled per second")
+	flags.Parse(args)
+
+	if flags.NArg() != 3 {
+		return fmt.Errorf("expected a model, a skeleton and a directory for the clips")
+	}
+	if *fps <= 0 {
+		return fmt.Errorf("fps should be positive, got %d", *fps)
+	}
+
+	model, err := LoadGLTF(flags.Arg(0), *fps)
+	if err != nil {
+		return err
+	}
+
+	if err := SaveSkeleton(flags.Arg(1), model.Tree, nil); err != nil {
+		return err
+	}
+	for i, anim := range model.Animations {
+		clip := filepath.Join(flags.Arg(2), model.AnimationNames[i]+".saf")
+		if err := SaveAnimation(clip, anim); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%d vertices, %d triangles, %d nodes, %d clips\n", len(model.Mesh.Positions),
+		len(model.Mesh.Indices)/3, len(model.Tree.Nodes), len(model.Animations))
+	return nil
+}
+
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	root := assetsFlag(flags)
+	fps := flags.Int("fps", 30, "frames sampled per second")
+	skeleton := flags.String("skeleton", "resources/skeletons/cube.sks", "skeleton the clips animate")
+	meshFile := flags.String("mesh", "resources/models/cube.obj", "mesh skinned to the skeleton")
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		return fmt.Errorf("expected an output model and at least one animation")
+	}
+
+	tree, _, err := LoadSkeleton(assetPath(*root, *skeleton))
+	if err != nil {
+		return err
+	}
+	mesh, err := LoadOBJ(assetPath(*root, *meshFile))
+	if err != nil {
+		return err
+	}
+	if err := mesh.checkSkin(tree); err != nil {
+		return fmt.Errorf("%s: %v", *meshFile, err)
+	}
+
+	anims := make([]Animation, 0)
+	names := make([]string, 0)
+	for _, clip := range flags.Args()[1:] {
+		anim, err := loadAnimation(clip)
+		if err != nil {
+			return err
+		}
+		if err := anim.checkNodes(tree); err != nil {
+			return fmt.Errorf("%s %v", clip, err)
+		}
+		anims = append(anims, anim)
+		names = append(names, strings.TrimSuffix(filepath.Base(clip), filepath.Ext(clip)))
+	}
+
+	if err := ExportGLTF(flags.Arg(0), mesh, tree, anims, names, *fps); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d nodes, %d clips\n", len(tree.Nodes), len(anims))
+	return nil
+}
+
+func renderCommand(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	root := assetsFlag(flags)
+	backend := flags.String("renderer", "gl", "gl or software")
+	width := flags.Int("width", windowWidth, "image width in pixels")
+	height := flags.Int("height", windowHeight, "image height in pixels")
+	skeleton := flags.String("skeleton", "resources/skeletons/cube.sks", "skeleton the clip animates")
+	meshFile := flags.String("mesh", "resources/models/cube.obj", "mesh skinned to the skeleton")
+	out := flags.String("out", ".", "directory the frames are written to")
+	frames := flags.Int("frames", 1, "frames rendered when no times are given")
+	fps := flags.Float64("fps", 30, "frame rate used with -frames")
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		return fmt.Errorf("expected an animation and optional times in seconds")
+	}
+	if *width <= 0 || *height <= 0 || *frames <= 0 || *fps <= 0 {
+		return fmt.Errorf("size, frames and fps should be positive")
+	}
+
+	times := make([]float64, 0)
+	for _, arg := range flags.Args()[1:] {
+		time, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return err
+		}
+		times = append(times, time)
+	}
+	if len(times) == 0 {
+		for i := 0; i < *frames; i++ {
+			times = append(times, float64(i) / *fps)
+		}
+	}
+
+	tree, constraints, err := LoadSkeleton(assetPath(*root, *skeleton))
+	if err != nil {
+		return err
+	}
+	anim := LoadAnimation(flags.Arg(0))
+
+	scene, err := NewOffscreenScene(*backend, *root, *width, *height, []string{assetPath(*root, *meshFile)})
+	if err != nil {
+		return err
+	}
+	defer scene.Delete()
+	if err := scene.checkSkin(tree); err != nil {
+		return err
+	}
+
+	for i, img := range scene.renderAnimation(tree, constraints, anim, times) {
+		filename := filepath.Join(*out, fmt.Sprintf("frame_%03d.png", i))
+		if err := SavePNG(filename, img); err != nil {
+			return err
+		}
+		fmt.Printf("%s at %gs\n", filename, times[i])
+	}
+	return nil
+}
+
+func captureCommand(args []string) error {
+	flags := flag.NewFlagSet("capture", flag.ExitOnError)
+	root := assetsFlag(flags)
+	backend := flags.String("renderer", "gl", "gl or software")
+	width := flags.Int("width", 400, "image width in pixels")
+	height := flags.Int("height", 300, "image height in pixels")
+	fps := flags.Float64("fps", 25, "frames captured per second")
+	duration := flags.Float64("duration", 0, "seconds captured, 0 plays the clip once")
+	dither := flags.Bool("dither", true, "dither the GIF palette")
+	skeleton := flags.String("skeleton", "resources/skeletons/cube.sks", "skeleton the clip animates")
+	meshFile := flags.String("mesh", "resources/models/cube.obj", "mesh skinned to the skeleton")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("expected an animation and an output GIF or directory")
+	}
+	if *width <= 0 || *height <= 0 || *fps <= 0 {
+		return fmt.Errorf("size and fps should be positive")
+	}
+
+	tree, constraints, err := LoadSkeleton(assetPath(*root, *skeleton))
+	if err != nil {
+		return err
+	}
+	anim := LoadAnimation(flags.Arg(0))
+
+	scene, err := NewOffscreenScene(*backend, *root, *width, *height, []string{assetPath(*root, *meshFile)})
+	if err != nil {
+		return err
+	}
+	defer scene.Delete()
+	if err := scene.checkSkin(tree); err != nil {
+		return err
+	}
+
+	times := captureTimes(anim, *fps, *duration)
+	frames := scene.renderAnimation(tree, constraints, anim, times)
+
+	out := flags.Arg(1)
+	if strings.EqualFold(filepath.Ext(out), ".gif") {
+		err = SaveGIF(out, frames, *fps, *dither)
+	} else {
+		err = SavePNGSequence(out, frames)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d frames at %dx%d, %g fps\n", out, len(frames), *width, *height, *fps)
+	return nil
+}
+
+func goldenCommand(args []string) error {
+	flags := flag.NewFlagSet("golden", flag.ExitOnError)
+	root := assetsFlag(flags)
+	opts := GoldenOptions{}
+	flags.StringVar(&opts.Renderer, "renderer", "gl", "gl or software")
+	flags.StringVar(&opts.Animations, "animations", "resources/animations", "directory of the rendered clips")
+	flags.StringVar(&opts.Skeleton, "skeleton", "resources/skeletons/cube.sks", "skeleton the clips animate")
+	flags.StringVar(&opts.Mesh, "mesh", "resources/models/cube.obj", "mesh skinned to the skeleton")
+	flags.StringVar(&opts.Golden, "golden", "resources/golden", "directory of the golden images")
+	flags.StringVar(&opts.Diff, "diff", "./golden-diff", "directory the diffs of failing frames are written to")
+	flags.Float64Var(&opts.Threshold, "threshold", 0.1, "perceptual difference above which a pixel differs")
+	flags.Float64Var(&opts.MaxMismatch, "max-mismatch", 0.005, "fraction of differing pixels allowed per frame")
+	flags.BoolVar(&opts.Update, "update", false, "rewrite the golden images from the current renderer")
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	opts.Assets = *root
+	frames, err := RunGolden(opts)
+	if err != nil {
+		return err
+	}
+	if opts.Update {
+		fmt.Printf("wrote %d golden images to %s\n", frames, opts.Golden)
+	} else {
+		fmt.Printf("%d frames match the golden images\n", frames)
+	}
+	return nil
+}
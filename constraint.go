@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Constraint adjusts the pose of a tree after the animations were applied
+type Constraint interface {
+	apply(t AnimationTree) AnimationTree
+}
+
+// a constraint that keeps moving with time, like spring bones after the
+// animation stops
+type timedConstraint interface {
+	update(t AnimationTree, currTime float64) AnimationTree
+}
+
+func applyConstraints(t AnimationTree, constraints []Constraint, currTime float64) AnimationTree {
+	for _, c := range constraints {
+		if timed, ok := c.(timedConstraint); ok {
+			t = timed.update(t, currTime)
+		} else {
+			t = c.apply(t)
+		}
+	}
+	return t
+}
+
+// AimConstraint turns a node, like a head or an eye, to look at a target
+type AimConstraint struct {
+	Node   int
+	Target mgl32.Vec3
+	// direction the node looks at in its rest pose
+	Forward mgl32.Vec3
+	// keeps the node from rolling around the aim direction
+	Up mgl32.Vec3
+	// maximum angle in radians away from the animated direction, 0 is unlimited
+	MaxAngle float32
+	// 0 keeps the animated pose, 1 fully looks at the target
+	Weight float32
+}
+
+func NewAimConstraint(t AnimationTree, node int) (AimConstraint, error) {
+	if node < 0 || node >= len(t.Nodes) {
+		return AimConstraint{}, fmt.Errorf("node %d is not part of the tree", node)
+	}
+
+	return AimConstraint{
+		Node:    node,
+		Forward: mgl32.Vec3{0.0, 0.0, 1.0},
+		Up:      mgl32.Vec3{0.0, 1.0, 0.0},
+		Weight:  1.0}, nil
+}
+
+func (c AimConstraint) apply(t AnimationTree) AnimationTree {
+	node := t.Nodes[c.Node]
+	joint := mgl32.Vec3(node.jointPosition())
+
+	desired := node.toJointSpace(c.Target).Sub(joint)
+	if desired.Len() < epsilon || c.Forward.Len() < epsilon {
+		return t
+	}
+
+	animated := node.Rotation.Rotate(c.Forward)
+	desired = clampDirection(desired, animated, c.MaxAngle)
+
+	aim := lookRotation(desired, c.Up).Mul(lookRotation(c.Forward, c.Up).Inverse())
+	rot := aim.Mul(node.Rotation.Inverse()).Normalize()
+	node.rotate(weighted(rot, c.Weight), joint)
+
+	return t
+}
+
+// returns the rotation taking the Z axis to forward and the Y axis as close
+// to up as possible
+func lookRotation(forward, up mgl32.Vec3) mgl32.Quat {
+	z := forward.Normalize()
+	x := up.Cross(z)
+	if x.Len() < epsilon {
+		x = perpendicular(z)
+	}
+	x = x.Normalize()
+	y := z.Cross(x)
+
+	return mgl32.Mat4ToQuat(mgl32.Mat3FromCols(x, y, z).Mat4()).Normalize()
+}
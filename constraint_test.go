@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// a head at (0, 1, 0) with a nose one unit in front of it along Z
+func headTree() AnimationTree {
+	head := NewAnimationNode([3]float32{0.0, 1.0, 0.0})
+	head.addChild([3]float32{0.0, 1.0, 1.0})
+
+	tree := AnimationTree{make([]*AnimationNode, 0), make([]SkinVertex, 0)}
+	tree.addNodes(&head)
+	return tree
+}
+
+// the direction the head looks at, from the head to the nose
+func looking(tree AnimationTree) mgl32.Vec3 {
+	return tree.Nodes[1].worldPosition().Sub(tree.Nodes[0].worldPosition()).Normalize()
+}
+
+func angleBetween(a, b mgl32.Vec3) float32 {
+	return float32(math.Acos(float64(mgl32.Clamp(a.Normalize().Dot(b.Normalize()), -1, 1))))
+}
+
+func TestAimConstraintLooksAtTarget(t *testing.T) {
+	tree := headTree()
+	aim, err := NewAimConstraint(tree, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []mgl32.Vec3{{3.0, 1.0, 0.0}, {0.0, 3.0, 2.0}, {-1.0, 0.0, -1.0}} {
+		tree.resetTree()
+		aim.Target = target
+		tree = aim.apply(tree)
+
+		want := target.Sub(tree.Nodes[0].worldPosition())
+		if angle := angleBetween(looking(tree), want); angle > 1e-3 {
+			t.Errorf("target %v: looking %v rad away", target, angle)
+		}
+		if l := boneLength(tree, 0, 1); mgl32.Abs(l-1.0) > 1e-3 {
+			t.Errorf("target %v: nose %v away from the head, want 1", target, l)
+		}
+	}
+}
+
+func TestAimConstraintLimitsAndWeight(t *testing.T) {
+	tree := headTree()
+	aim, _ := NewAimConstraint(tree, 0)
+	aim.Target = mgl32.Vec3{3.0, 1.0, 0.0}
+	forward := mgl32.Vec3{0.0, 0.0, 1.0}
+
+	aim.MaxAngle = 0.5
+	tree = aim.apply(tree)
+	if angle := angleBetween(looking(tree), forward); mgl32.Abs(angle-0.5) > 1e-3 {
+		t.Errorf("turned %v rad, limit 0.5", angle)
+	}
+
+	aim.MaxAngle = 0.0
+	aim.Weight = 0.5
+	tree.resetTree()
+	tree = aim.apply(tree)
+	if angle := angleBetween(looking(tree), forward); mgl32.Abs(angle-math.Pi/4) > 1e-3 {
+		t.Errorf("turned %v rad at half weight, want a quarter turn halved", angle)
+	}
+
+	aim.Weight = 0.0
+	tree.resetTree()
+	tree = aim.apply(tree)
+	if angle := angleBetween(looking(tree), forward); angle > 1e-3 {
+		t.Errorf("turned %v rad at weight 0", angle)
+	}
+}
+
+func TestRenderAnimationAppliesConstraints(t *testing.T) {
+	tree, constraints, err := LoadSkeleton("resources/skeletons/cube_rig.sks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scene, err := NewOffscreenScene("software", ".", 64, 48, []string{"resources/models/cube.obj"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scene.Delete()
+
+	anim := LoadAnimation("resources/animations/bounce.saf")
+	times := []float64{0.5}
+	plain := scene.renderAnimation(tree, nil, anim, times)[0]
+	constrained := scene.renderAnimation(tree, constraints, anim, times)[0]
+
+	if mismatched, _ := compareImages(plain, constrained, 0.1); mismatched == 0 {
+		t.Error("the constraints of the skeleton do not change the rendered pose")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// environment variable read when the -log flag is not given
+const logEnv = "TROLLHOUSE_LOG"
+
+// the level of each log category, info unless configured otherwise
+var logLevels = map[string]*slog.LevelVar{
+	"animation": new(slog.LevelVar),
+	"render":    new(slog.LevelVar),
+	"assets":    new(slog.LevelVar),
+}
+
+var (
+	animationLog = newCategoryLogger("animation")
+	renderLog    = newCategoryLogger("render")
+	assetsLog    = newCategoryLogger("assets")
+)
+
+func newCategoryLogger(category string) *slog.Logger {
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevels[category]})
+	return slog.New(handler).With("category", category)
+}
+
+// configureLogging sets the log levels from a spec such as "debug" for
+// every category or "warn,animation=debug" for a default and overrides.
+// Overrides win over the default wherever it is listed, and nothing
+// changes when part of the spec is invalid.
+func configureLogging(spec string) error {
+	var defaultLevel *slog.Level
+	overrides := make(map[string]slog.Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		category, levelName, found := strings.Cut(part, "=")
+		if !found {
+			category, levelName = "", part
+		}
+
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(levelName)); err != nil {
+			return fmt.Errorf("log level %q: %v", levelName, err)
+		}
+
+		if category == "" {
+			defaultLevel = &level
+			continue
+		}
+		if _, ok := logLevels[category]; !ok {
+			return fmt.Errorf("unknown log category %q, expected animation, render or assets", category)
+		}
+		overrides[category] = level
+	}
+
+	for category, l := range logLevels {
+		if level, ok := overrides[category]; ok {
+			l.Set(level)
+		} else if defaultLevel != nil {
+			l.Set(*defaultLevel)
+		}
+	}
+	return nil
+}
+
+// the state of one node in a pose dump
+type nodePose struct {
+	Index       int        `json:"index"`
+	Name        string     `json:"name,omitempty"`
+	Pos         [3]float32 `json:"pos"`
+	Translation [3]float32 `json:"translation"`
+	RotationY   float32    `json:"rotationY"`
+	// w, x, y, z
+	Rotation [4]float32 `json:"rotation"`
+	Scale    [3]float32 `json:"scale"`
+	// joint position after scale and Y rotation, as drawn
+	World    [3]float32 `json:"world"`
+	Children []int      `json:"children"`
+}
+
+type poseDump struct {
+	Time  float64    `json:"time"`
+	Nodes []nodePose `json:"nodes"`
+}
+
+// DumpPose writes the current state of every node of the tree as JSON
+func DumpPose(filename string, tree AnimationTree, time float64) error {
+	index := make(map[*AnimationNode]int)
+	for i, node := range tree.Nodes {
+		index[node] = i
+	}
+
+	dump := poseDump{Time: time, Nodes: make([]nodePose, len(tree.Nodes))}
+	for i, node := range tree.Nodes {
+		children := make([]int, 0, len(node.Children))
+		for _, child := range node.Children {
+			if j, ok := index[child]; ok {
+				children = append(children, j)
+			}
+		}
+
+		dump.Nodes[i] = nodePose{
+			Index:       i,
+			Name:        node.Name,
+			Pos:         node.Pos,
+			Translation: node.Translation,
+			RotationY:   node.RotationY,
+			Rotation:    [4]float32{node.Rotation.W, node.Rotation.V[0], node.Rotation.V[1], node.Rotation.V[2]},
+			Scale:       node.Scale,
+			World:       node.worldPosition(),
+			Children:    children,
+		}
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// poseDumpName returns a file name for a pose dump taken now
+func poseDumpName() string {
+	return "pose-" + time.Now().Format("20060102-150405.000") + ".json"
+}
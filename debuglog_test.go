@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+)
+
+// returns the level of every category after configuring the spec from info
+func configuredLevels(t *testing.T, spec string) (map[string]slog.Level, error) {
+	for _, l := range logLevels {
+		l.Set(slog.LevelInfo)
+	}
+	t.Cleanup(func() {
+		for _, l := range logLevels {
+			l.Set(slog.LevelInfo)
+		}
+	})
+
+	err := configureLogging(spec)
+	levels := make(map[string]slog.Level)
+	for category, l := range logLevels {
+		levels[category] = l.Level()
+	}
+	return levels, err
+}
+
+func TestConfigureLogging(t *testing.T) {
+	specs := map[string]map[string]slog.Level{
+		"debug":                {"animation": slog.LevelDebug, "render": slog.LevelDebug, "assets": slog.LevelDebug},
+		"warn,animation=debug": {"animation": slog.LevelDebug, "render": slog.LevelWarn, "assets": slog.LevelWarn},
+		// the override still wins when the default comes after it
+		"animation=debug, warn": {"animation": slog.LevelDebug, "render": slog.LevelWarn, "assets": slog.LevelWarn},
+		"render=error":          {"animation": slog.LevelInfo, "render": slog.LevelError, "assets": slog.LevelInfo},
+	}
+
+	for spec, want := range specs {
+		levels, err := configuredLevels(t, spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		for category, level := range want {
+			if levels[category] != level {
+				t.Errorf("%q sets %s to %v, want %v", spec, category, levels[category], level)
+			}
+		}
+	}
+}
+
+func TestConfigureLoggingRejectsBadSpecs(t *testing.T) {
+	for _, spec := range []string{"debug,physics=debug", "loud", "animation=loud", "warn,render="} {
+		levels, err := configuredLevels(t, spec)
+		if err == nil {
+			t.Errorf("%q accepted", spec)
+		}
+		for category, level := range levels {
+			if level != slog.LevelInfo {
+				t.Errorf("%q set %s to %v before failing", spec, category, level)
+			}
+		}
+	}
+}
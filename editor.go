@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// returns the index of the timestamp at a time point, or -1
+func (a *Animation) keyframeAt(timePoint int) int {
+	for i, ts := range (*a).TimeStamps {
+		if ts.TimePoint == timePoint {
+			return i
+		}
+	}
+	return -1
+}
+
+// returns the time point closest to a time in seconds. When the grid of time
+// points is too coarse to land within half a step of it, the clip is moved
+// to a grid of step seconds first, which keeps every existing key in place.
+func (a *Animation) timePointAt(time float64, step float64) int {
+	duration := float64((*a).TimeStampDuration)
+	timePoint := int(math.Round(time / duration))
+	if math.Abs(float64(timePoint)*duration-time) <= step/2 || duration <= step {
+		return timePoint
+	}
+
+	n := int(math.Round(duration / step))
+	if n > 1 {
+		for i := range (*a).TimeStamps {
+			(*a).TimeStamps[i].TimePoint *= n
+		}
+		(*a).TimeStampDuration /= float32(n)
+	}
+	return int(math.Round(time / float64((*a).TimeStampDuration)))
+}
+
+// adds a keyframe at a time point inside the clip, holding the pose the clip
+// has there, and returns its index; an existing keyframe is returned as is
+func (a *Animation) insertKeyframe(timePoint int) int {
+	if i := a.keyframeAt(timePoint); i >= 0 {
+		return i
+	}
+
+	keys := a.sample(float32(timePoint) * (*a).TimeStampDuration)
+	ts := AnimationTimeStamp{TimePoint: timePoint, Translations: keys}
+
+	at := len((*a).TimeStamps)
+	for i, other := range (*a).TimeStamps {
+		if other.TimePoint > timePoint {
+			at = i
+			break
+		}
+	}
+	(*a).TimeStamps = append((*a).TimeStamps, AnimationTimeStamp{})
+	copy((*a).TimeStamps[at+1:], (*a).TimeStamps[at:])
+	(*a).TimeStamps[at] = ts
+	return at
+}
+
+// removes a keyframe; the last one sets the length of the loop and stays
+func (a *Animation) deleteKeyframe(index int) error {
+	if index < 0 || index >= len((*a).TimeStamps) {
+		return fmt.Errorf("no keyframe %d", index)
+	}
+	if index == len((*a).TimeStamps)-1 {
+		return fmt.Errorf("the last keyframe sets the length of the clip")
+	}
+	(*a).TimeStamps = append((*a).TimeStamps[:index], (*a).TimeStamps[index+1:]...)
+	return nil
+}
+
+// returns the key of a node at a keyframe. A node the clip does not animate
+// yet gets a rest key in every timestamp that lacks one, so the edited pose
+// blends with the rest of the clip.
+func (a *Animation) nodeKey(index, node int) *NodeAnimationTranslation {
+	var key *NodeAnimationTranslation
+	for i := range (*a).TimeStamps {
+		keys := (*a).TimeStamps[i].Translations
+		found := -1
+		for j := range keys {
+			if keys[j].NodeIdx == node {
+				found = j
+				break
+			}
+		}
+		if found < 0 {
+			(*a).TimeStamps[i].Translations = append(keys, restKey(node))
+			found = len(keys)
+		}
+		if i == index {
+			key = &(*a).TimeStamps[i].Translations[found]
+		}
+	}
+	return key
+}
+
+type EditChannel int
+
+const (
+	EditTranslation EditChannel = iota
+	EditRotation
+	EditScale
+)
+
+var editChannelNames = []string{"translation", "rotation", "scale"}
+
+// KeyframeEditor edits the keys of the clip a Playback is showing: it picks
+// a node and nudges its key at the current time, inserting and deleting
+// keyframes, and saves the clip next to the file it came from
+type KeyframeEditor struct {
+	Enabled bool
+	// the edited node
+	Node    int
+	Channel EditChannel
+	// a nudge moves translation and scale by Step and turns by RotationStep
+	// radians
+	Step         float32
+	RotationStep float32
+
+	playback *Playback
+	// clips with unsaved edits
+	dirty map[int]bool
+}
+
+func NewKeyframeEditor(playback *Playback) *KeyframeEditor {
+	return &KeyframeEditor{
+		Step:         0.05,
+		RotationStep: mgl32.DegToRad(5.0),
+		playback:     playback,
+		dirty:        make(map[int]bool),
+	}
+}
+
+// entering the editor pauses playback
+func (e *KeyframeEditor) toggle() {
+	(*e).Enabled = !(*e).Enabled
+	if (*e).Enabled {
+		(*e).playback.Paused = true
+	}
+}
+
+func (e *KeyframeEditor) clip() *Animation {
+	return &(*e).playback.Clips[(*e).playback.Current]
+}
+
+// moves the selection through the nodes of a tree of count nodes
+func (e *KeyframeEditor) selectNode(delta, count int) {
+	if count == 0 {
+		return
+	}
+	(*e).Node = (((*e).Node+delta)%count + count) % count
+}
+
+// index of the keyframe at the playback time, or -1 between keyframes
+func (e *KeyframeEditor) currentKey() int {
+	p := (*e).playback
+	index := p.keyframeIndex()
+	if math.Abs(p.keyframeTime(index)-p.Time) > 1e-4 {
+		return -1
+	}
+	return index
+}
+
+// adds a keyframe at the playback time, snapped to the clip's time points,
+// and returns its index
+func (e *KeyframeEditor) insertKey() int {
+	p := (*e).playback
+	clip := e.clip()
+	timePoint := clip.timePointAt(p.Time, p.FrameStep)
+	if last := clip.TimeStamps[len(clip.TimeStamps)-1].TimePoint; timePoint >= last {
+		// past the loop end, where the clip starts over
+		timePoint = last
+	}
+
+	index := clip.insertKeyframe(timePoint)
+	p.Time = p.keyframeTime(index)
+	(*e).dirty[p.Current] = true
+	return index
+}
+
+func (e *KeyframeEditor) deleteKey() error {
+	index := e.currentKey()
+	if index < 0 {
+		return fmt.Errorf("no keyframe at %.2fs", (*e).playback.Time)
+	}
+	if err := e.clip().deleteKeyframe(index); err != nil {
+		return err
+	}
+	(*e).dirty[(*e).playback.Current] = true
+	return nil
+}
+
+// nudges the selected node's key at the playback time along an axis, 0 to
+// 2 for X to Z, by steps; a key is inserted first between keyframes
+func (e *KeyframeEditor) nudge(axis int, steps float32) {
+	index := e.currentKey()
+	if index < 0 {
+		index = e.insertKey()
+	}
+	key := e.clip().nodeKey(index, (*e).Node)
+
+	switch (*e).Channel {
+	case EditTranslation:
+		key.Translation[axis] += steps * (*e).Step
+	case EditRotation:
+		var around mgl32.Vec3
+		around[axis] = 1.0
+		key.Rotation = mgl32.QuatRotate(steps*(*e).RotationStep, around).Mul(key.Rotation).Normalize()
+	case EditScale:
+		key.Scale[axis] = float32(math.Max(0.01, float64(key.Scale[axis]+steps*(*e).Step)))
+	}
+	(*e).dirty[(*e).playback.Current] = true
+}
+
+// writes the current clip next to its file, see editedFile, and returns
+// the file written
+func (e *KeyframeEditor) save() (string, error) {
+	p := (*e).playback
+	filename := editedFile(p.Files[p.Current])
+	if err := SaveAnimation(filename, *e.clip()); err != nil {
+		return "", err
+	}
+	delete((*e).dirty, p.Current)
+	return filename, nil
+}
+
+// returns the file edits of a clip are saved to, clip.edited.saf for
+// clip.saf, so the shipped clips are never overwritten
+func editedFile(filename string) string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if strings.HasSuffix(base, ".edited") {
+		return filename
+	}
+	return base + ".edited" + filepath.Ext(filename)
+}
+
+// a line describing the editor state for the window title
+func (e *KeyframeEditor) status(tree AnimationTree) string {
+	name := ""
+	if (*e).Node < len(tree.Nodes) {
+		name = tree.Nodes[(*e).Node].Name
+	}
+	unsaved := ""
+	if (*e).dirty[(*e).playback.Current] {
+		unsaved = " *"
+	}
+	return fmt.Sprintf("edit node %d %s %s%s", (*e).Node, name, editChannelNames[(*e).Channel], unsaved)
+}
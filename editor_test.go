@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNodeKeyFillsOnlyMissingTimestamps(t *testing.T) {
+	anim := turningClip(0.0, 1.0, 2.0)
+	other := restKey(1)
+	other.Translation = [3]float32{0.0, 0.5, 0.0}
+	anim.TimeStamps[1].Translations = append(anim.TimeStamps[1].Translations, other)
+
+	key := anim.nodeKey(0, 1)
+	key.Translation[0] = 1.0
+
+	for i, ts := range anim.TimeStamps {
+		count := 0
+		for _, k := range ts.Translations {
+			if k.NodeIdx == 1 {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Errorf("timestamp %d has %d keys of node 1, want 1", i, count)
+		}
+	}
+	if got := anim.nodeKey(1, 1).Translation; got != other.Translation {
+		t.Errorf("existing key changed to %v", got)
+	}
+	if got := anim.nodeKey(0, 1).Translation[0]; got != 1.0 {
+		t.Errorf("edited key lost, translation x is %v", got)
+	}
+}
+
+func TestEditedFile(t *testing.T) {
+	dir := filepath.Join("resources", "animations")
+	for filename, want := range map[string]string{
+		filepath.Join(dir, "jump.saf"):        filepath.Join(dir, "jump.edited.saf"),
+		filepath.Join(dir, "jump.edited.saf"): filepath.Join(dir, "jump.edited.saf"),
+	} {
+		if got := editedFile(filename); got != want {
+			t.Errorf("%s saved to %s, want %s", filename, got, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"image"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// GLRenderer draws with the animation shaders into the framebuffer bound
+// to the current OpenGL context
+type GLRenderer struct {
+	Width  int
+	Height int
+
+	program  uint32
+	meshes   []GPUMesh
+	textures map[string]uint32
+
+	projectionUniform int32
+	cameraUniform     int32
+	modelUniform      int32
+	animationUniformT int32
+	animationUniformR int32
+	animationUniformS int32
+	animationUniformM int32
+}
+
+// creates a window with a current OpenGL 4.1 core context; glfw must be
+// initialized and the window stays hidden unless visible is set. Visible
+// windows can be resized and are scaled by the monitor's content scale, so
+// their framebuffer may be larger than width x height on HiDPI screens.
+func newContext(width, height int, title string, visible bool) (*glfw.Window, error) {
+	glfw.WindowHint(glfw.ContextVersionMajor, 4)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	if visible {
+		glfw.WindowHint(glfw.Visible, glfw.True)
+		glfw.WindowHint(glfw.Resizable, glfw.True)
+		glfw.WindowHint(glfw.ScaleToMonitor, glfw.True)
+	} else {
+		glfw.WindowHint(glfw.Visible, glfw.False)
+		glfw.WindowHint(glfw.Resizable, glfw.False)
+		glfw.WindowHint(glfw.ScaleToMonitor, glfw.False)
+	}
+
+	window, err := glfw.CreateWindow(width, height, title, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	window.MakeContextCurrent()
+
+	// Initialize Glow
+	if err := gl.Init(); err != nil {
+		window.Destroy()
+		return nil, err
+	}
+	return window, nil
+}
+
+// NewGLRenderer loads the shaders and the texture used by meshes without
+// materials; a context must be current
+func NewGLRenderer(vertexShader, fragmentShader, defaultTexture string, width, height int) (*GLRenderer, error) {
+	program, err := LoadShaderProgram(vertexShader, fragmentShader)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &GLRenderer{
+		Width:    width,
+		Height:   height,
+		program:  program,
+		meshes:   make([]GPUMesh, 0),
+		textures: make(map[string]uint32),
+	}
+
+	gl.UseProgram(program)
+	r.projectionUniform = gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	r.cameraUniform = gl.GetUniformLocation(program, gl.Str("camera\x00"))
+	r.modelUniform = gl.GetUniformLocation(program, gl.Str("model\x00"))
+	r.animationUniformT = gl.GetUniformLocation(program, gl.Str("animT\x00"))
+	r.animationUniformR = gl.GetUniformLocation(program, gl.Str("animR\x00"))
+	r.animationUniformS = gl.GetUniformLocation(program, gl.Str("animS\x00"))
+	r.animationUniformM = gl.GetUniformLocation(program, gl.Str("animM\x00"))
+
+	textureUniform := gl.GetUniformLocation(program, gl.Str("tex\x00"))
+	gl.Uniform1i(textureUniform, 0)
+
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	if err := r.loadTexture("", defaultTexture); err != nil {
+		r.Delete()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *GLRenderer) loadTexture(key, filename string) error {
+	if _, ok := (*r).textures[key]; ok {
+		return nil
+	}
+	texture, err := LoadTexture(filename)
+	if err != nil {
+		return err
+	}
+	(*r).textures[key] = texture
+	return nil
+}
+
+func (r *GLRenderer) addMesh(mesh Mesh) error {
+	for _, part := range mesh.Parts {
+		if err := r.loadTexture(part.Material.DiffuseMap, part.Material.DiffuseMap); err != nil {
+			return err
+		}
+	}
+
+	gpuMesh, err := NewGPUMesh(mesh, (*r).program)
+	if err != nil {
+		return err
+	}
+	(*r).meshes = append((*r).meshes, gpuMesh)
+	return nil
+}
+
+func (r *GLRenderer) draw(frame Frame) {
+	gl.Viewport(0, 0, int32((*r).Width), int32((*r).Height))
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.ClearColor(0.1, 0.1, 0.1, 1.0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	gl.UseProgram((*r).program)
+	gl.UniformMatrix4fv((*r).projectionUniform, 1, false, &frame.Projection[0])
+	gl.UniformMatrix4fv((*r).cameraUniform, 1, false, &frame.Camera[0])
+	gl.UniformMatrix4fv((*r).modelUniform, 1, false, &frame.Model[0])
+
+	tree := frame.Tree
+	if len(tree.Nodes) > 0 {
+		t, rot, s := tree.getAnimation()
+		m := tree.getJointMatrices()
+
+		gl.Uniform3fv((*r).animationUniformT,
+			int32(len(tree.Nodes)),
+			&(t[0]))
+		gl.Uniform1fv((*r).animationUniformR,
+			int32(len(tree.Nodes)),
+			&(rot[0]))
+		gl.Uniform3fv((*r).animationUniformS,
+			int32(len(tree.Nodes)),
+			&(s[0]))
+		gl.UniformMatrix4fv((*r).animationUniformM,
+			int32(len(tree.Nodes)),
+			false,
+			&(m[0]))
+	}
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	for _, mesh := range (*r).meshes {
+		if len(mesh.Parts) == 0 {
+			gl.BindTexture(gl.TEXTURE_2D, (*r).textures[""])
+			mesh.draw()
+		}
+		for _, part := range mesh.Parts {
+			gl.BindTexture(gl.TEXTURE_2D, (*r).textures[part.Material.DiffuseMap])
+			mesh.drawPart(part)
+		}
+	}
+}
+
+// changes the size of the viewport, in framebuffer pixels
+func (r *GLRenderer) resize(width, height int) {
+	(*r).Width = width
+	(*r).Height = height
+}
+
+// reads the frame back from the bound framebuffer
+func (r *GLRenderer) image() *image.NRGBA {
+	gl.Finish()
+	return readPixels((*r).Width, (*r).Height)
+}
+
+// releases the meshes, textures and program of the renderer
+func (r *GLRenderer) Delete() {
+	for i := range (*r).meshes {
+		(*r).meshes[i].Delete()
+	}
+	for _, texture := range (*r).textures {
+		gl.DeleteTextures(1, &texture)
+	}
+	gl.DeleteProgram((*r).program)
+
+	(*r).meshes = nil
+	(*r).textures = make(map[string]uint32)
+}
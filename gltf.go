@@ -0,0 +1,886 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// subset of the glTF 2.0 schema used by the importer and the exporter
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       *int             `json:"scene,omitempty"`
+	Scenes      []gltfScene      `json:"scenes,omitempty"`
+	Nodes       []gltfNode       `json:"nodes,omitempty"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Skins       []gltfSkin       `json:"skins,omitempty"`
+	Animations  []gltfAnimation  `json:"animations,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string    `json:"name,omitempty"`
+	Children    []int     `json:"children,omitempty"`
+	Mesh        *int      `json:"mesh,omitempty"`
+	Skin        *int      `json:"skin,omitempty"`
+	Matrix      []float32 `json:"matrix,omitempty"`
+	Translation []float32 `json:"translation,omitempty"`
+	Rotation    []float32 `json:"rotation,omitempty"`
+	Scale       []float32 `json:"scale,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices,omitempty"`
+	Mode       *int           `json:"mode,omitempty"`
+}
+
+type gltfSkin struct {
+	InverseBindMatrices *int  `json:"inverseBindMatrices,omitempty"`
+	Joints              []int `json:"joints"`
+}
+
+type gltfAnimation struct {
+	Name     string                 `json:"name,omitempty"`
+	Channels []gltfChannel          `json:"channels"`
+	Samplers []gltfAnimationSampler `json:"samplers"`
+}
+
+type gltfChannel struct {
+	Sampler int `json:"sampler"`
+	Target  struct {
+		Node *int   `json:"node,omitempty"`
+		Path string `json:"path"`
+	} `json:"target"`
+}
+
+type gltfAnimationSampler struct {
+	Input         int    `json:"input"`
+	Interpolation string `json:"interpolation,omitempty"`
+	Output        int    `json:"output"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int      `json:"bufferView,omitempty"`
+	ByteOffset    int       `json:"byteOffset,omitempty"`
+	ComponentType int       `json:"componentType"`
+	Normalized    bool      `json:"normalized,omitempty"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+	Sparse        *struct{} `json:"sparse,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int  `json:"buffer"`
+	ByteOffset int  `json:"byteOffset,omitempty"`
+	ByteLength int  `json:"byteLength"`
+	ByteStride int  `json:"byteStride,omitempty"`
+	Target     *int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri,omitempty"`
+	ByteLength int    `json:"byteLength"`
+}
+
+const (
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+)
+
+var gltfComponents = map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT4": 16}
+
+const (
+	glbMagic     = 0x46546C67
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+// GLTFModel is what LoadGLTF reads from a .gltf or .glb file. The tree holds
+// the joints of the first skin, or every node when the model has no skin,
+// and the primitives of every mesh are merged into a single one skinned to
+// the tree.
+type GLTFModel struct {
+	Mesh Mesh
+	Tree AnimationTree
+	// indexed like Tree.Nodes
+	InverseBindMatrices []mgl32.Mat4
+	Animations          []Animation
+	AnimationNames      []string
+}
+
+type gltfFile struct {
+	doc     gltfDocument
+	buffers [][]byte
+}
+
+// LoadGLTF reads a glTF 2.0 model. Animations are sampled at the given frame
+// rate, which keeps STEP and CUBICSPLINE samplers exact at every frame.
+func LoadGLTF(filename string, fps int) (GLTFModel, error) {
+	f, err := readGLTFFile(filename)
+	if err != nil {
+		return GLTFModel{}, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	model, err := f.model(fps)
+	if err != nil {
+		return GLTFModel{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return model, nil
+}
+
+func readGLTFFile(filename string) (gltfFile, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return gltfFile{}, err
+	}
+
+	var f gltfFile
+	var glbBuffer []byte
+	if len(content) >= 12 && binary.LittleEndian.Uint32(content) == glbMagic {
+		var jsonChunk []byte
+		jsonChunk, glbBuffer, err = splitGLB(content)
+		if err != nil {
+			return gltfFile{}, err
+		}
+		content = jsonChunk
+	}
+
+	if err := json.Unmarshal(content, &f.doc); err != nil {
+		return gltfFile{}, err
+	}
+	if !strings.HasPrefix(f.doc.Asset.Version, "2.") {
+		return gltfFile{}, fmt.Errorf("unsupported glTF version %q", f.doc.Asset.Version)
+	}
+
+	for i, buffer := range f.doc.Buffers {
+		var data []byte
+		switch {
+		case buffer.URI == "" && i == 0 && glbBuffer != nil:
+			data = glbBuffer
+		case strings.HasPrefix(buffer.URI, "data:"):
+			comma := strings.Index(buffer.URI, ",")
+			if comma < 0 || !strings.HasSuffix(buffer.URI[:comma], ";base64") {
+				return gltfFile{}, fmt.Errorf("buffer %d has an unsupported data uri", i)
+			}
+			data, err = base64.StdEncoding.DecodeString(buffer.URI[comma+1:])
+		case buffer.URI != "":
+			data, err = ioutil.ReadFile(filepath.Join(filepath.Dir(filename), filepath.FromSlash(buffer.URI)))
+		default:
+			err = fmt.Errorf("buffer %d has no data", i)
+		}
+		if err != nil {
+			return gltfFile{}, err
+		}
+		if len(data) < buffer.ByteLength {
+			return gltfFile{}, fmt.Errorf("buffer %d is shorter than %d bytes", i, buffer.ByteLength)
+		}
+		f.buffers = append(f.buffers, data)
+	}
+
+	return f, nil
+}
+
+// returns the JSON and the binary chunk of a .glb file
+func splitGLB(content []byte) ([]byte, []byte, error) {
+	if version := binary.LittleEndian.Uint32(content[4:]); version != 2 {
+		return nil, nil, fmt.Errorf("unsupported glb version %d", version)
+	}
+
+	var jsonChunk, binChunk []byte
+	for offset := 12; offset+8 <= len(content); {
+		length := int(binary.LittleEndian.Uint32(content[offset:]))
+		kind := binary.LittleEndian.Uint32(content[offset+4:])
+		if offset+8+length > len(content) {
+			return nil, nil, fmt.Errorf("truncated glb chunk")
+		}
+
+		chunk := content[offset+8 : offset+8+length]
+		switch kind {
+		case glbChunkJSON:
+			jsonChunk = chunk
+		case glbChunkBIN:
+			binChunk = chunk
+		}
+		offset += 8 + length
+	}
+
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf("glb has no JSON chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+// returns the accessor values as floats, converting normalized integers,
+// together with the number of components per element
+func (f gltfFile) floats(idx int) ([]float32, int, error) {
+	if idx < 0 || idx >= len(f.doc.Accessors) {
+		return nil, 0, fmt.Errorf("accessor %d does not exist", idx)
+	}
+	acc := f.doc.Accessors[idx]
+	if acc.Sparse != nil {
+		return nil, 0, fmt.Errorf("sparse accessors are not supported")
+	}
+
+	n, ok := gltfComponents[acc.Type]
+	if !ok {
+		return nil, 0, fmt.Errorf("accessor %d has unsupported type %q", idx, acc.Type)
+	}
+
+	if acc.Count < 0 || acc.ByteOffset < 0 {
+		return nil, 0, fmt.Errorf("accessor %d has a negative count or offset", idx)
+	}
+	values := make([]float32, acc.Count*n)
+	if acc.BufferView == nil {
+		return values, n, nil
+	}
+
+	if *acc.BufferView < 0 || *acc.BufferView >= len(f.doc.BufferViews) {
+		return nil, 0, fmt.Errorf("accessor %d uses buffer view %d, which does not exist", idx, *acc.BufferView)
+	}
+	view := f.doc.BufferViews[*acc.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(f.buffers) {
+		return nil, 0, fmt.Errorf("buffer view %d uses buffer %d, which does not exist", *acc.BufferView, view.Buffer)
+	}
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 {
+		return nil, 0, fmt.Errorf("buffer view %d has a negative offset, length or stride", *acc.BufferView)
+	}
+	size := componentSize(acc.ComponentType)
+	if size == 0 {
+		return nil, 0, fmt.Errorf("accessor %d has unsupported component type %d", idx, acc.ComponentType)
+	}
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = size * n
+	}
+
+	data := f.buffers[view.Buffer]
+	for i := 0; i < acc.Count; i++ {
+		for c := 0; c < n; c++ {
+			offset := view.ByteOffset + acc.ByteOffset + i*stride + c*size
+			if offset+size > len(data) || offset+size > view.ByteOffset+view.ByteLength {
+				return nil, 0, fmt.Errorf("accessor %d reads past its buffer view", idx)
+			}
+			values[i*n+c] = componentValue(data[offset:], acc.ComponentType, acc.Normalized)
+		}
+	}
+
+	return values, n, nil
+}
+
+func componentSize(componentType int) int {
+	switch componentType {
+	case gltfByte, gltfUnsignedByte:
+		return 1
+	case gltfShort, gltfUnsignedShort:
+		return 2
+	case gltfUnsignedInt, gltfFloat:
+		return 4
+	}
+	return 0
+}
+
+func componentValue(b []byte, componentType int, normalized bool) float32 {
+	switch componentType {
+	case gltfByte:
+		v := float32(int8(b[0]))
+		if normalized {
+			return float32(math.Max(float64(v/127), -1))
+		}
+		return v
+	case gltfUnsignedByte:
+		v := float32(b[0])
+		if normalized {
+			return v / 255
+		}
+		return v
+	case gltfShort:
+		v := float32(int16(binary.LittleEndian.Uint16(b)))
+		if normalized {
+			return float32(math.Max(float64(v/32767), -1))
+		}
+		return v
+	case gltfUnsignedShort:
+		v := float32(binary.LittleEndian.Uint16(b))
+		if normalized {
+			return v / 65535
+		}
+		return v
+	case gltfUnsignedInt:
+		return float32(binary.LittleEndian.Uint32(b))
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+// returns the rest transform of a node relative to its parent
+func (n gltfNode) local() (mgl32.Vec3, mgl32.Quat, mgl32.Vec3) {
+	if len(n.Matrix) == 16 {
+		var m mgl32.Mat4
+		copy(m[:], n.Matrix)
+		return decompose(m)
+	}
+
+	t := mgl32.Vec3{}
+	r := mgl32.QuatIdent()
+	s := mgl32.Vec3{1.0, 1.0, 1.0}
+	if len(n.Translation) == 3 {
+		t = mgl32.Vec3{n.Translation[0], n.Translation[1], n.Translation[2]}
+	}
+	if len(n.Rotation) == 4 {
+		r = mgl32.Quat{W: n.Rotation[3], V: mgl32.Vec3{n.Rotation[0], n.Rotation[1], n.Rotation[2]}}.Normalize()
+	}
+	if len(n.Scale) == 3 {
+		s = mgl32.Vec3{n.Scale[0], n.Scale[1], n.Scale[2]}
+	}
+	return t, r, s
+}
+
+func composeTRS(t mgl32.Vec3, r mgl32.Quat, s mgl32.Vec3) mgl32.Mat4 {
+	return mgl32.Translate3D(t[0], t[1], t[2]).Mul4(r.Mat4()).Mul4(mgl32.Scale3D(s[0], s[1], s[2]))
+}
+
+// splits an affine matrix into translation, rotation and scale
+func decompose(m mgl32.Mat4) (mgl32.Vec3, mgl32.Quat, mgl32.Vec3) {
+	t := m.Col(3).Vec3()
+	s := mgl32.Vec3{m.Col(0).Vec3().Len(), m.Col(1).Vec3().Len(), m.Col(2).Vec3().Len()}
+
+	var r mgl32.Mat4
+	for c := 0; c < 3; c++ {
+		col := m.Col(c).Vec3()
+		if s[c] > epsilon {
+			col = col.Mul(1 / s[c])
+		}
+		r.SetCol(c, col.Vec4(0))
+	}
+	r.SetCol(3, mgl32.Vec4{0, 0, 0, 1})
+
+	return t, mgl32.Mat4ToQuat(r).Normalize(), s
+}
+
+// returns the parent of every node, -1 for scene roots
+func (f gltfFile) parents() ([]int, error) {
+	parents := make([]int, len(f.doc.Nodes))
+	for i := range parents {
+		parents[i] = -1
+	}
+	for i, node := range f.doc.Nodes {
+		for _, child := range node.Children {
+			if child < 0 || child >= len(parents) {
+				return nil, fmt.Errorf("node %d has child %d, which does not exist", i, child)
+			}
+			if parents[child] >= 0 {
+				return nil, fmt.Errorf("node %d is the child of both %d and %d", child, parents[child], i)
+			}
+			parents[child] = i
+		}
+	}
+
+	// a node among its own ancestors would never reach a root
+	for i := range parents {
+		steps := 0
+		for p := parents[i]; p >= 0; p = parents[p] {
+			if steps++; steps > len(parents) {
+				return nil, fmt.Errorf("node %d is its own ancestor", i)
+			}
+		}
+	}
+	return parents, nil
+}
+
+// returns the global transform of every node given the local ones
+func globalTransforms(locals []mgl32.Mat4, parents []int) []mgl32.Mat4 {
+	globals := make([]mgl32.Mat4, len(locals))
+	done := make([]bool, len(locals))
+
+	var resolve func(i int) mgl32.Mat4
+	resolve = func(i int) mgl32.Mat4 {
+		if !done[i] {
+			done[i] = true
+			globals[i] = locals[i]
+			if parents[i] >= 0 {
+				globals[i] = resolve(parents[i]).Mul4(locals[i])
+			}
+		}
+		return globals[i]
+	}
+
+	for i := range locals {
+		resolve(i)
+	}
+	return globals
+}
+
+func (f gltfFile) model(fps int) (GLTFModel, error) {
+	var model GLTFModel
+	parents, err := f.parents()
+	if err != nil {
+		return GLTFModel{}, err
+	}
+
+	locals := make([]mgl32.Mat4, len(f.doc.Nodes))
+	for i, node := range f.doc.Nodes {
+		t, r, s := node.local()
+		locals[i] = composeTRS(t, r, s)
+	}
+	rest := globalTransforms(locals, parents)
+
+	// pick the nodes of the skeleton, parents first
+	var joints []int
+	var bind []mgl32.Mat4
+	if len(f.doc.Skins) > 0 {
+		skin := f.doc.Skins[0]
+		for _, joint := range skin.Joints {
+			if joint < 0 || joint >= len(f.doc.Nodes) {
+				return GLTFModel{}, fmt.Errorf("skin joint %d does not exist", joint)
+			}
+		}
+		joints = sortParentsFirst(skin.Joints, parents)
+
+		inverse := make(map[int]mgl32.Mat4)
+		if skin.InverseBindMatrices != nil {
+			values, n, err := f.floats(*skin.InverseBindMatrices)
+			if err != nil {
+				return GLTFModel{}, err
+			}
+			if n != 16 || len(values) < 16*len(skin.Joints) {
+				return GLTFModel{}, fmt.Errorf("inverse bind matrices do not match the joints")
+			}
+			for i, joint := range skin.Joints {
+				var m mgl32.Mat4
+				copy(m[:], values[i*16:])
+				inverse[joint] = m
+			}
+		}
+
+		for _, joint := range joints {
+			if m, ok := inverse[joint]; ok {
+				bind = append(bind, m)
+			} else {
+				bind = append(bind, rest[joint].Inv())
+			}
+		}
+	} else {
+		for i := range f.doc.Nodes {
+			joints = append(joints, i)
+		}
+		joints = sortParentsFirst(joints, parents)
+		for _, joint := range joints {
+			bind = append(bind, rest[joint].Inv())
+		}
+	}
+
+	// build the animation tree from the bind pose
+	treeIdx := make(map[int]int)
+	bindRotations := make([]mgl32.Quat, len(joints))
+	model.Tree = AnimationTree{make([]*AnimationNode, 0), make([]SkinVertex, 0)}
+	for i, joint := range joints {
+		t, r, _ := decompose(bind[i].Inv())
+		bindRotations[i] = r
+
+		node := NewAnimationNode(t)
+		node.Name = f.doc.Nodes[joint].Name
+		if node.Name == "" {
+			node.Name = fmt.Sprintf("node%d", joint)
+		}
+		node.Name = strings.Join(strings.Fields(node.Name), "_")
+
+		for p := parents[joint]; p >= 0; p = parents[p] {
+			if parentIdx, ok := treeIdx[p]; ok {
+				model.Tree.Nodes[parentIdx].Children = append(model.Tree.Nodes[parentIdx].Children, &node)
+				break
+			}
+		}
+		treeIdx[joint] = len(model.Tree.Nodes)
+		model.Tree.Nodes = append(model.Tree.Nodes, &node)
+	}
+	model.InverseBindMatrices = bind
+
+	if err := f.readMeshes(&model, treeIdx); err != nil {
+		return GLTFModel{}, err
+	}
+
+	for i, anim := range f.doc.Animations {
+		clip, err := f.sampleAnimation(anim, fps, joints, parents, bindRotations, model.Tree)
+		if err != nil {
+			return GLTFModel{}, fmt.Errorf("animation %d: %v", i, err)
+		}
+
+		name := anim.Name
+		if name == "" {
+			name = fmt.Sprintf("animation%d", i)
+		}
+		model.Animations = append(model.Animations, clip)
+		model.AnimationNames = append(model.AnimationNames, name)
+	}
+
+	return model, nil
+}
+
+// orders nodes so every parent comes before its children
+func sortParentsFirst(nodes []int, parents []int) []int {
+	depth := func(i int) int {
+		d := 0
+		for p := parents[i]; p >= 0; p = parents[p] {
+			d++
+		}
+		return d
+	}
+
+	sorted := append([]int(nil), nodes...)
+	sort.SliceStable(sorted, func(a, b int) bool { return depth(sorted[a]) < depth(sorted[b]) })
+	return sorted
+}
+
+func (f gltfFile) readMeshes(model *GLTFModel, treeIdx map[int]int) error {
+	var skinJoints []int
+	if len(f.doc.Skins) > 0 {
+		skinJoints = f.doc.Skins[0].Joints
+	}
+
+	for _, node := range f.doc.Nodes {
+		if node.Mesh == nil {
+			continue
+		}
+		if *node.Mesh < 0 || *node.Mesh >= len(f.doc.Meshes) {
+			return fmt.Errorf("mesh %d does not exist", *node.Mesh)
+		}
+
+		for _, prim := range f.doc.Meshes[*node.Mesh].Primitives {
+			if prim.Mode != nil && *prim.Mode != 4 {
+				return fmt.Errorf("only triangle primitives are supported, found mode %d", *prim.Mode)
+			}
+			if err := f.readPrimitive(model, prim, skinJoints, treeIdx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f gltfFile) readPrimitive(model *GLTFModel, prim gltfPrimitive, skinJoints []int, treeIdx map[int]int) error {
+	posIdx, ok := prim.Attributes["POSITION"]
+	if !ok {
+		return fmt.Errorf("primitive without positions")
+	}
+	positions, err := f.attribute(posIdx, 3, -1)
+	if err != nil {
+		return fmt.Errorf("POSITION: %v", err)
+	}
+
+	base := len((*model).Mesh.Positions)
+	count := len(positions) / 3
+	for i := 0; i < count; i++ {
+		(*model).Mesh.Positions = append((*model).Mesh.Positions, [3]float32{positions[i*3], positions[i*3+1], positions[i*3+2]})
+	}
+
+	normals := make([]float32, count*3)
+	if idx, ok := prim.Attributes["NORMAL"]; ok {
+		if normals, err = f.attribute(idx, 3, count); err != nil {
+			return fmt.Errorf("NORMAL: %v", err)
+		}
+	}
+	uvs := make([]float32, count*2)
+	if idx, ok := prim.Attributes["TEXCOORD_0"]; ok {
+		if uvs, err = f.attribute(idx, 2, count); err != nil {
+			return fmt.Errorf("TEXCOORD_0: %v", err)
+		}
+	}
+	for i := 0; i < count; i++ {
+		(*model).Mesh.Normals = append((*model).Mesh.Normals, [3]float32{normals[i*3], normals[i*3+1], normals[i*3+2]})
+		(*model).Mesh.UVs = append((*model).Mesh.UVs, [2]float32{uvs[i*2], uvs[i*2+1]})
+	}
+
+	if prim.Indices != nil {
+		indices, err := f.attribute(*prim.Indices, 1, -1)
+		if err != nil {
+			return fmt.Errorf("indices: %v", err)
+		}
+		for _, idx := range indices {
+			if idx < 0 || int(idx) >= count {
+				return fmt.Errorf("index %g is not one of the %d vertices", idx, count)
+			}
+			(*model).Mesh.Indices = append((*model).Mesh.Indices, uint32(base)+uint32(idx))
+		}
+	} else {
+		for i := 0; i < count; i++ {
+			(*model).Mesh.Indices = append((*model).Mesh.Indices, uint32(base+i))
+		}
+	}
+
+	jointsIdx, hasJoints := prim.Attributes["JOINTS_0"]
+	weightsIdx, hasWeights := prim.Attributes["WEIGHTS_0"]
+	if !hasJoints || !hasWeights || skinJoints == nil {
+		return nil
+	}
+
+	joints, err := f.attribute(jointsIdx, 4, count)
+	if err != nil {
+		return fmt.Errorf("JOINTS_0: %v", err)
+	}
+	weights, err := f.attribute(weightsIdx, 4, count)
+	if err != nil {
+		return fmt.Errorf("WEIGHTS_0: %v", err)
+	}
+
+	for i := 0; i < count; i++ {
+		sv := SkinVertex{base + i, make(map[int]float32)}
+		for c := 0; c < 4; c++ {
+			j := int(joints[i*4+c])
+			w := weights[i*4+c]
+			if w <= 0 || j >= len(skinJoints) {
+				continue
+			}
+			sv.Weights[treeIdx[skinJoints[j]]] += w
+		}
+		(*model).Mesh.Skin = append((*model).Mesh.Skin, sv)
+	}
+	return nil
+}
+
+// returns the values of an accessor with the given number of components per
+// element and, unless count is negative, at least count elements
+func (f gltfFile) attribute(idx, components, count int) ([]float32, error) {
+	values, n, err := f.floats(idx)
+	if err != nil {
+		return nil, err
+	}
+	if n != components {
+		return nil, fmt.Errorf("accessor %d has %d components per element, expected %d", idx, n, components)
+	}
+	if count >= 0 && len(values) < count*components {
+		return nil, fmt.Errorf("accessor %d has %d elements, expected %d", idx, len(values)/components, count)
+	}
+	return values, nil
+}
+
+type gltfTrack struct {
+	times         []float32
+	values        []float32
+	width         int
+	interpolation string
+}
+
+// evaluates the sampler at a time, clamping outside its keyframes
+func (t gltfTrack) at(time float32) []float32 {
+	n := len(t.times)
+	value := func(k int) []float32 {
+		if t.interpolation == "CUBICSPLINE" {
+			// in-tangent, value, out-tangent
+			return t.values[(k*3+1)*t.width : (k*3+2)*t.width]
+		}
+		return t.values[k*t.width : (k+1)*t.width]
+	}
+
+	if time <= t.times[0] {
+		return value(0)
+	}
+	if time >= t.times[n-1] {
+		return value(n - 1)
+	}
+
+	k := sort.Search(n, func(i int) bool { return t.times[i] > time }) - 1
+	dt := t.times[k+1] - t.times[k]
+	s := (time - t.times[k]) / dt
+
+	result := make([]float32, t.width)
+	switch t.interpolation {
+	case "STEP":
+		copy(result, value(k))
+	case "CUBICSPLINE":
+		v0, v1 := value(k), value(k+1)
+		out0 := t.values[(k*3+2)*t.width : (k*3+3)*t.width]
+		in1 := t.values[((k+1)*3)*t.width : ((k+1)*3+1)*t.width]
+
+		s2, s3 := s*s, s*s*s
+		for c := range result {
+			result[c] = (2*s3-3*s2+1)*v0[c] + (s3-2*s2+s)*dt*out0[c] + (-2*s3+3*s2)*v1[c] + (s3-s2)*dt*in1[c]
+		}
+	default:
+		v0, v1 := value(k), value(k+1)
+		if t.width == 4 {
+			q := mgl32.QuatSlerp(
+				mgl32.Quat{W: v0[3], V: mgl32.Vec3{v0[0], v0[1], v0[2]}},
+				mgl32.Quat{W: v1[3], V: mgl32.Vec3{v1[0], v1[1], v1[2]}}, s)
+			return []float32{q.V[0], q.V[1], q.V[2], q.W}
+		}
+		for c := range result {
+			result[c] = lerp(v0[c], v1[c], s)
+		}
+	}
+	return result
+}
+
+// samples the channels of a glTF animation at a fixed frame rate and turns
+// the global pose of every frame into keys of the animation tree
+func (f gltfFile) sampleAnimation(anim gltfAnimation, fps int, joints []int, parents []int,
+	bindRotations []mgl32.Quat, tree AnimationTree) (Animation, error) {
+
+	type nodeTracks struct {
+		translation, rotation, scale *gltfTrack
+	}
+	tracks := make(map[int]*nodeTracks)
+
+	var duration float32
+	for _, channel := range anim.Channels {
+		if channel.Target.Node == nil || channel.Target.Path == "weights" {
+			continue
+		}
+		if channel.Sampler < 0 || channel.Sampler >= len(anim.Samplers) {
+			return Animation{}, fmt.Errorf("sampler %d does not exist", channel.Sampler)
+		}
+		sampler := anim.Samplers[channel.Sampler]
+
+		node := *channel.Target.Node
+		if node < 0 || node >= len(f.doc.Nodes) {
+			return Animation{}, fmt.Errorf("channel targets node %d, which does not exist", node)
+		}
+		widths := map[string]int{"translation": 3, "rotation": 4, "scale": 3}
+		width, ok := widths[channel.Target.Path]
+		if !ok {
+			return Animation{}, fmt.Errorf("unknown channel path %q", channel.Target.Path)
+		}
+
+		times, err := f.attribute(sampler.Input, 1, -1)
+		if err != nil {
+			return Animation{}, err
+		}
+		values, err := f.attribute(sampler.Output, width, -1)
+		if err != nil {
+			return Animation{}, err
+		}
+		if len(times) == 0 {
+			continue
+		}
+
+		interpolation := sampler.Interpolation
+		if interpolation == "" {
+			interpolation = "LINEAR"
+		}
+		if interpolation != "LINEAR" && interpolation != "STEP" && interpolation != "CUBICSPLINE" {
+			return Animation{}, fmt.Errorf("unknown interpolation %q", interpolation)
+		}
+		frames := 1
+		if interpolation == "CUBICSPLINE" {
+			frames = 3
+		}
+		if len(values) < len(times)*frames*width {
+			return Animation{}, fmt.Errorf("sampler %d has too few values", channel.Sampler)
+		}
+
+		track := &gltfTrack{times, values, width, interpolation}
+		if times[len(times)-1] > duration {
+			duration = times[len(times)-1]
+		}
+
+		if tracks[node] == nil {
+			tracks[node] = &nodeTracks{}
+		}
+		switch channel.Target.Path {
+		case "translation":
+			tracks[node].translation = track
+		case "rotation":
+			tracks[node].rotation = track
+		case "scale":
+			tracks[node].scale = track
+		}
+	}
+
+	step := 1.0 / float32(fps)
+	frames := int(math.Ceil(float64(duration * float32(fps))))
+	anim2 := Animation{0.0, step, make([]AnimationTimeStamp, 0, frames+1)}
+
+	for frame := 0; frame <= frames; frame++ {
+		time := mgl32.Clamp(float32(frame)*step, 0, duration)
+
+		// local transforms of every node at this time
+		locals := make([]mgl32.Mat4, len(f.doc.Nodes))
+		scales := make([]mgl32.Vec3, len(f.doc.Nodes))
+		for i, node := range f.doc.Nodes {
+			t, r, s := node.local()
+			if nt, ok := tracks[i]; ok {
+				if nt.translation != nil {
+					v := nt.translation.at(time)
+					t = mgl32.Vec3{v[0], v[1], v[2]}
+				}
+				if nt.rotation != nil {
+					v := nt.rotation.at(time)
+					r = mgl32.Quat{W: v[3], V: mgl32.Vec3{v[0], v[1], v[2]}}.Normalize()
+				}
+				if nt.scale != nil {
+					v := nt.scale.at(time)
+					s = mgl32.Vec3{v[0], v[1], v[2]}
+				}
+			}
+			locals[i] = composeTRS(t, r, s)
+			scales[i] = s
+		}
+		globals := globalTransforms(locals, parents)
+
+		anim2.TimeStamps = append(anim2.TimeStamps, AnimationTimeStamp{frame, poseKeys(tree, joints, globals, scales, bindRotations)})
+	}
+
+	tree.resetTree()
+	return anim2, nil
+}
+
+// returns the keys that make Animation.animate reproduce the global pose of
+// the joints, by replaying them on the tree
+func poseKeys(tree AnimationTree, joints []int, globals []mgl32.Mat4, scales []mgl32.Vec3, bindRotations []mgl32.Quat) []NodeAnimationTranslation {
+	tree.resetTree()
+	keys := make([]NodeAnimationTranslation, len(joints))
+
+	for i, joint := range joints {
+		node := tree.Nodes[i]
+		position, rotation, _ := decompose(globals[joint])
+
+		// the shader scales after translating, with the scale of the parents
+		scale := scales[joint]
+		for c := range position {
+			position[c] = safeDiv(position[c], node.Scale[c]*scale[c])
+		}
+
+		key := NodeAnimationTranslation{
+			NodeIdx:     i,
+			Translation: position.Sub(node.jointPosition()),
+			Scale:       scale,
+			// the tree starts from the bind pose, so only the change matters
+			Rotation: node.Rotation.Inverse().Mul(rotation.Mul(bindRotations[i].Inverse())).Normalize()}
+
+		node.translate(key.Translation)
+		node.rotate(node.Rotation.Mul(key.Rotation).Mul(node.Rotation.Inverse()), node.jointPosition())
+		node.scale(key.Scale)
+
+		keys[i] = key
+	}
+	return keys
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+var columnFiles = []string{"resources/models/column.gltf", "resources/models/column.glb"}
+
+func TestLoadGLTFColumn(t *testing.T) {
+	for _, filename := range columnFiles {
+		model, err := LoadGLTF(filename, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nodes := model.Tree.Nodes
+		if len(nodes) != 2 || nodes[0].Name != "root" || nodes[1].Pos != [3]float32{0.0, 1.0, 0.0} || nodes[0].Children[0] != nodes[1] {
+			t.Fatalf("%s: tree loaded as %v", filename, nodes)
+		}
+		mesh := model.Mesh
+		if len(mesh.Positions) != 12 || len(mesh.Indices) != 48 || len(mesh.Skin) != 12 || mesh.Skin[5].Weights[1] != 0.5 {
+			t.Errorf("%s: %d vertices, %d indices and %d skin entries, want 12, 48 and 12",
+				filename, len(mesh.Positions), len(mesh.Indices), len(mesh.Skin))
+		}
+		if len(model.Animations) != 3 || model.AnimationNames[1] != "hop" {
+			t.Fatalf("%s: clips %v, want bend, hop and sway", filename, model.AnimationNames)
+		}
+	}
+}
+
+func TestLoadGLTFColumnPoses(t *testing.T) {
+	for _, filename := range columnFiles {
+		model, err := LoadGLTF(filename, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree := model.Tree
+
+		// bend turns the tip 60 degrees about Z halfway through
+		tree.resetTree()
+		model.Animations[0].animate(tree, 0.5)
+		want := mgl32.QuatRotate(mgl32.DegToRad(60.0), mgl32.Vec3{0.0, 0.0, 1.0})
+		if !tree.Nodes[1].Rotation.OrientationEqualThreshold(want, 1e-4) {
+			t.Errorf("%s: bend rotates the tip by %v", filename, tree.Nodes[1].Rotation)
+		}
+
+		// hop steps the root up by half a unit without interpolating
+		for time, y := range map[float64]float32{0.1: 0.0, 0.25: 0.0, 0.5: 0.5, 0.75: 0.5} {
+			tree.resetTree()
+			model.Animations[1].animate(tree, time)
+			if p := tree.Nodes[1].worldPosition(); mgl32.Abs(p[1]-(1.0+y)) > 1e-4 {
+				t.Errorf("%s: hop puts the tip at %v at %vs", filename, p, time)
+			}
+		}
+
+		// sway leans the column about 20 degrees about X a quarter through
+		tree.resetTree()
+		model.Animations[2].animate(tree, 0.25)
+		p := tree.Nodes[1].worldPosition()
+		if angle := mgl32.RadToDeg(float32(math.Atan2(float64(p[2]), float64(p[1])))); mgl32.Abs(angle-20.0) > 0.5 {
+			t.Errorf("%s: sway leans the tip %v degrees, want 20", filename, angle)
+		}
+	}
+}
+
+// writes column.gltf after letting edit change the decoded document
+func brokenColumn(t *testing.T, edit func(doc map[string]interface{})) string {
+	content, err := os.ReadFile("resources/models/column.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+	edit(doc)
+
+	content, err = json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "column.gltf")
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadGLTFRejectsMalformedFiles(t *testing.T) {
+	item := func(doc map[string]interface{}, key string, idx int) map[string]interface{} {
+		return doc[key].([]interface{})[idx].(map[string]interface{})
+	}
+	edits := map[string]func(doc map[string]interface{}){
+		"missing buffer view": func(doc map[string]interface{}) { item(doc, "accessors", 1)["bufferView"] = 99 },
+		"missing buffer":      func(doc map[string]interface{}) { item(doc, "bufferViews", 0)["buffer"] = 3 },
+		"negative count":      func(doc map[string]interface{}) { item(doc, "accessors", 0)["count"] = -1 },
+		"short normals":       func(doc map[string]interface{}) { item(doc, "accessors", 1)["count"] = 6 },
+		"short uvs":           func(doc map[string]interface{}) { item(doc, "accessors", 2)["count"] = 6 },
+		"index past vertices": func(doc map[string]interface{}) { item(doc, "accessors", 0)["count"] = 4 },
+		"missing skin joint":  func(doc map[string]interface{}) { item(doc, "skins", 0)["joints"] = []int{1, 7} },
+		"parent cycle":        func(doc map[string]interface{}) { item(doc, "nodes", 2)["children"] = []int{0} },
+		"missing child":       func(doc map[string]interface{}) { item(doc, "nodes", 2)["children"] = []int{9} },
+		"missing channel node": func(doc map[string]interface{}) {
+			item(item(doc, "animations", 0), "channels", 0)["target"].(map[string]interface{})["node"] = 9
+		},
+	}
+
+	for name, edit := range edits {
+		if _, err := LoadGLTF(brokenColumn(t, edit), 20); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
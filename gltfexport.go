@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+)
+
+type gltfBuilder struct {
+	doc gltfDocument
+	bin bytes.Buffer
+}
+
+// ExportGLTF writes the tree as a glTF skeleton, the mesh skinned to it and
+// every animation sampled at the given frame rate into a single .glb file.
+// Joints keep the indices of the tree nodes.
+func ExportGLTF(filename string, mesh Mesh, tree AnimationTree, anims []Animation, names []string, fps int) error {
+	if len(tree.Nodes) == 0 {
+		return fmt.Errorf("the animation tree has no nodes")
+	}
+	if len(anims) != len(names) {
+		return fmt.Errorf("expected a name for each of the %d animations", len(anims))
+	}
+	if fps <= 0 {
+		return fmt.Errorf("fps should be positive, got %d", fps)
+	}
+
+	b := gltfBuilder{doc: gltfDocument{Asset: gltfAsset{Version: "2.0", Generator: "trollhouse"}}}
+	parents := make([]int, len(tree.Nodes))
+	for i := range tree.Nodes {
+		parents[i] = tree.parentOf(i)
+	}
+
+	// joints in their bind pose, where nodes are only positioned
+	scene := gltfScene{make([]int, 0)}
+	inverseBind := make([]float32, 0, len(tree.Nodes)*16)
+	joints := make([]int, len(tree.Nodes))
+	for i, node := range tree.Nodes {
+		offset := mgl32.Vec3(node.Pos)
+		if parents[i] >= 0 {
+			offset = offset.Sub(tree.Nodes[parents[i]].Pos)
+		} else {
+			scene.Nodes = append(scene.Nodes, i)
+		}
+
+		b.doc.Nodes = append(b.doc.Nodes, gltfNode{Name: node.Name, Translation: offset[:]})
+		for _, child := range node.Children {
+			for j, n := range tree.Nodes {
+				if n == child {
+					b.doc.Nodes[i].Children = append(b.doc.Nodes[i].Children, j)
+				}
+			}
+		}
+
+		m := mgl32.Translate3D(-node.Pos[0], -node.Pos[1], -node.Pos[2])
+		inverseBind = append(inverseBind, m[:]...)
+		joints[i] = i
+	}
+
+	if len(mesh.Positions) > 0 {
+		meshIdx, skinIdx := 0, 0
+		prim, err := b.addMesh(mesh)
+		if err != nil {
+			return err
+		}
+		b.doc.Meshes = []gltfMesh{{Name: "mesh", Primitives: []gltfPrimitive{prim}}}
+
+		ibm := b.addFloats(inverseBind, "MAT4", -1, false)
+		b.doc.Skins = []gltfSkin{{InverseBindMatrices: &ibm, Joints: joints}}
+
+		scene.Nodes = append(scene.Nodes, len(b.doc.Nodes))
+		b.doc.Nodes = append(b.doc.Nodes, gltfNode{Name: "mesh", Mesh: &meshIdx, Skin: &skinIdx})
+	}
+
+	for i, anim := range anims {
+		b.addAnimation(anim, names[i], tree, parents, fps)
+	}
+	tree.resetTree()
+
+	sceneIdx := 0
+	b.doc.Scene = &sceneIdx
+	b.doc.Scenes = []gltfScene{scene}
+
+	return b.writeGLB(filename)
+}
+
+// appends raw data as a new buffer view, keeping every view 4-byte aligned
+func (b *gltfBuilder) addView(data interface{}, target int) int {
+	for (*b).bin.Len()%4 != 0 {
+		(*b).bin.WriteByte(0)
+	}
+	offset := (*b).bin.Len()
+	binary.Write(&(*b).bin, binary.LittleEndian, data)
+
+	view := gltfBufferView{Buffer: 0, ByteOffset: offset, ByteLength: (*b).bin.Len() - offset}
+	if target >= 0 {
+		view.Target = &target
+	}
+	(*b).doc.BufferViews = append((*b).doc.BufferViews, view)
+	return len((*b).doc.BufferViews) - 1
+}
+
+func (b *gltfBuilder) addAccessor(view, componentType, count int, typ string) int {
+	(*b).doc.Accessors = append((*b).doc.Accessors, gltfAccessor{
+		BufferView:    &view,
+		ComponentType: componentType,
+		Count:         count,
+		Type:          typ})
+	return len((*b).doc.Accessors) - 1
+}
+
+// adds float values, optionally with the min and max that POSITION and
+// animation inputs require
+func (b *gltfBuilder) addFloats(values []float32, typ string, target int, bounds bool) int {
+	n := gltfComponents[typ]
+	idx := b.addAccessor(b.addView(values, target), gltfFloat, len(values)/n, typ)
+
+	if bounds && len(values) >= n {
+		min := append([]float32(nil), values[:n]...)
+		max := append([]float32(nil), values[:n]...)
+		for i, v := range values {
+			min[i%n] = float32(math.Min(float64(min[i%n]), float64(v)))
+			max[i%n] = float32(math.Max(float64(max[i%n]), float64(v)))
+		}
+		(*b).doc.Accessors[idx].Min = min
+		(*b).doc.Accessors[idx].Max = max
+	}
+	return idx
+}
+
+func (b *gltfBuilder) addMesh(mesh Mesh) (gltfPrimitive, error) {
+	count := len(mesh.Positions)
+	for _, idx := range mesh.Indices {
+		if int(idx) >= count {
+			return gltfPrimitive{}, fmt.Errorf("index %d is out of the %d vertices", idx, count)
+		}
+	}
+
+	positions := make([]float32, 0, count*3)
+	for _, p := range mesh.Positions {
+		positions = append(positions, p[:]...)
+	}
+	prim := gltfPrimitive{Attributes: map[string]int{
+		"POSITION": b.addFloats(positions, "VEC3", gltfArrayBuffer, true)}}
+
+	if len(mesh.Normals) == count {
+		normals := make([]float32, 0, count*3)
+		for _, n := range mesh.Normals {
+			normals = append(normals, n[:]...)
+		}
+		prim.Attributes["NORMAL"] = b.addFloats(normals, "VEC3", gltfArrayBuffer, false)
+	}
+	if len(mesh.UVs) == count {
+		uvs := make([]float32, 0, count*2)
+		for _, uv := range mesh.UVs {
+			uvs = append(uvs, uv[:]...)
+		}
+		prim.Attributes["TEXCOORD_0"] = b.addFloats(uvs, "VEC2", gltfArrayBuffer, false)
+	}
+
+	// four strongest influences per vertex; glTF wants every vertex of a
+	// skinned mesh weighted, so unskinned ones follow the first node
+	jointIndices := make([]uint16, count*4)
+	weights := make([]float32, count*4)
+	for i := 0; i < count; i++ {
+		weights[i*4] = 1.0
+	}
+	for _, sv := range mesh.Skin {
+		if sv.VertexIdx < 0 || sv.VertexIdx >= count {
+			return gltfPrimitive{}, fmt.Errorf("skin vertex %d is out of the %d vertices", sv.VertexIdx, count)
+		}
+
+		nodes := make([]int, 0, len(sv.Weights))
+		var sum float32
+		for node, w := range sv.Weights {
+			if w > 0.0 {
+				nodes = append(nodes, node)
+			}
+		}
+		sort.Slice(nodes, func(a, b int) bool {
+			if sv.Weights[nodes[a]] != sv.Weights[nodes[b]] {
+				return sv.Weights[nodes[a]] > sv.Weights[nodes[b]]
+			}
+			return nodes[a] < nodes[b]
+		})
+		if len(nodes) > 4 {
+			nodes = nodes[:4]
+		}
+		for _, node := range nodes {
+			sum += sv.Weights[node]
+		}
+		if sum <= 0.0 {
+			continue
+		}
+
+		for c := 0; c < 4; c++ {
+			jointIndices[sv.VertexIdx*4+c] = 0
+			weights[sv.VertexIdx*4+c] = 0.0
+			if c < len(nodes) {
+				jointIndices[sv.VertexIdx*4+c] = uint16(nodes[c])
+				weights[sv.VertexIdx*4+c] = sv.Weights[nodes[c]] / sum
+			}
+		}
+	}
+	prim.Attributes["JOINTS_0"] = b.addAccessor(b.addView(jointIndices, gltfArrayBuffer), gltfUnsignedShort, count, "VEC4")
+	prim.Attributes["WEIGHTS_0"] = b.addFloats(weights, "VEC4", gltfArrayBuffer, false)
+
+	if len(mesh.Indices) > 0 {
+		indices := b.addAccessor(b.addView(mesh.Indices, gltfElementArrayBuffer), gltfUnsignedInt, len(mesh.Indices), "SCALAR")
+		prim.Indices = &indices
+	}
+	return prim, nil
+}
+
+// samples the animation on the tree and stores the local transform of
+// every node at each frame as linear channels
+func (b *gltfBuilder) addAnimation(anim Animation, name string, tree AnimationTree, parents []int, fps int) {
+	duration := anim.duration()
+	frames := int(math.Ceil(float64(duration*float32(fps)) - 1e-4))
+
+	times := make([]float32, 0, frames+1)
+	translations := make([][]float32, len(tree.Nodes))
+	rotations := make([][]float32, len(tree.Nodes))
+	scales := make([][]float32, len(tree.Nodes))
+
+	for frame := 0; frame <= frames; frame++ {
+		time := float32(math.Min(float64(frame)/float64(fps), float64(duration)))
+		times = append(times, time)
+
+		tree.resetTree()
+		anim.animate(tree, anim.StartTime+float64(time))
+
+		// global transform of every joint, from its bind pose
+		skin := tree.skinMatrices()
+		globals := make([]mgl32.Mat4, len(tree.Nodes))
+		for i, node := range tree.Nodes {
+			globals[i] = skin[i].Mul4(mgl32.Translate3D(node.Pos[0], node.Pos[1], node.Pos[2]))
+		}
+
+		for i := range tree.Nodes {
+			local := globals[i]
+			if parents[i] >= 0 {
+				local = globals[parents[i]].Inv().Mul4(local)
+			}
+			t, r, s := decompose(local)
+			translations[i] = append(translations[i], t[:]...)
+			rotations[i] = append(rotations[i], r.V[0], r.V[1], r.V[2], r.W)
+			scales[i] = append(scales[i], s[:]...)
+		}
+	}
+
+	gltfAnim := gltfAnimation{Name: name}
+	input := b.addFloats(times, "SCALAR", -1, true)
+	for i := range tree.Nodes {
+		node := i
+		for _, track := range []struct {
+			path   string
+			values []float32
+			typ    string
+		}{
+			{"translation", translations[i], "VEC3"},
+			{"rotation", rotations[i], "VEC4"},
+			{"scale", scales[i], "VEC3"},
+		} {
+			sampler := gltfAnimationSampler{Input: input, Interpolation: "LINEAR", Output: b.addFloats(track.values, track.typ, -1, false)}
+			channel := gltfChannel{Sampler: len(gltfAnim.Samplers)}
+			channel.Target.Node = &node
+			channel.Target.Path = track.path
+
+			gltfAnim.Samplers = append(gltfAnim.Samplers, sampler)
+			gltfAnim.Channels = append(gltfAnim.Channels, channel)
+		}
+	}
+	(*b).doc.Animations = append((*b).doc.Animations, gltfAnim)
+}
+
+func (b *gltfBuilder) writeGLB(filename string) error {
+	for (*b).bin.Len()%4 != 0 {
+		(*b).bin.WriteByte(0)
+	}
+	(*b).doc.Buffers = []gltfBuffer{{ByteLength: (*b).bin.Len()}}
+
+	content, err := json.Marshal((*b).doc)
+	if err != nil {
+		return err
+	}
+	// the JSON chunk is padded with spaces
+	for len(content)%4 != 0 {
+		content = append(content, ' ')
+	}
+
+	var glb bytes.Buffer
+	binary.Write(&glb, binary.LittleEndian, []uint32{glbMagic, 2, uint32(12 + 8 + len(content) + 8 + (*b).bin.Len())})
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(content)), glbChunkJSON})
+	glb.Write(content)
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32((*b).bin.Len()), glbChunkBIN})
+	glb.Write((*b).bin.Bytes())
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(glb.Bytes()); err != nil {
+		return err
+	}
+	return file.Close()
+}
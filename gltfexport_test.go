@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// returns the mesh vertices skinned to the pose of the tree the way glTF
+// skins them, blending the whole matrices of the nodes; the mesh should
+// have passed checkSkin for the tree
+func skinPositions(tree AnimationTree, mesh Mesh) []mgl32.Vec3 {
+	m := tree.skinMatrices()
+	positions := make([]mgl32.Vec3, len(mesh.Positions))
+	for i, p := range mesh.Positions {
+		positions[i] = mgl32.Vec3(p)
+	}
+
+	for _, sv := range mesh.Skin {
+		var sum float32
+		for _, w := range sv.Weights {
+			sum += w
+		}
+		if sum <= 0.0 || sv.VertexIdx >= len(positions) {
+			continue
+		}
+
+		v := positions[sv.VertexIdx].Vec4(1.0)
+		result := mgl32.Vec4{}
+		for node, w := range sv.Weights {
+			result = result.Add(m[node].Mul4x1(v).Mul(w / sum))
+		}
+		positions[sv.VertexIdx] = result.Vec3()
+	}
+	return positions
+}
+
+// reimports an exported file and returns the largest distance between the
+// skinned mesh vertices of both versions over every sampled frame
+func gltfRoundTripError(filename string, mesh Mesh, tree AnimationTree, anims []Animation, fps int) (float32, error) {
+	model, err := LoadGLTF(filename, fps)
+	if err != nil {
+		return 0.0, err
+	}
+	if len(model.Tree.Nodes) != len(tree.Nodes) || len(model.Animations) != len(anims) {
+		return 0.0, fmt.Errorf("read %d nodes and %d animations back, expected %d and %d",
+			len(model.Tree.Nodes), len(model.Animations), len(tree.Nodes), len(anims))
+	}
+	if len(model.Mesh.Positions) != len(mesh.Positions) {
+		return 0.0, fmt.Errorf("read %d vertices back, expected %d", len(model.Mesh.Positions), len(mesh.Positions))
+	}
+	if err := model.Mesh.checkSkin(model.Tree); err != nil {
+		return 0.0, err
+	}
+
+	var maxErr float32
+	for i, anim := range anims {
+		duration := anim.duration()
+		for frame := 0; float32(frame) <= duration*float32(fps); frame++ {
+			time := float64(frame) / float64(fps)
+
+			tree.resetTree()
+			want := skinPositions(anim.animate(tree, anim.StartTime+time), mesh)
+			model.Tree.resetTree()
+			got := skinPositions(model.Animations[i].animate(model.Tree, model.Animations[i].StartTime+time), model.Mesh)
+
+			for v := range want {
+				maxErr = float32(math.Max(float64(maxErr), float64(want[v].Sub(got[v]).Len())))
+			}
+		}
+	}
+	tree.resetTree()
+
+	return maxErr, nil
+}
+
+func TestExportGLTFRoundTrip(t *testing.T) {
+	tree, _, err := LoadSkeleton("resources/skeletons/cube.sks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesh, err := LoadOBJ("resources/models/cube.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mesh.checkSkin(tree); err != nil {
+		t.Fatal(err)
+	}
+	clips, err := filepath.Glob("resources/animations/*.saf")
+	if err != nil || len(clips) == 0 {
+		t.Fatalf("no clips: %v", err)
+	}
+
+	anims := make([]Animation, 0)
+	names := make([]string, 0)
+	for _, clip := range clips {
+		anims = append(anims, LoadAnimation(clip))
+		names = append(names, filepath.Base(clip))
+	}
+
+	filename := filepath.Join(t.TempDir(), "cube.glb")
+	if err := ExportGLTF(filename, mesh, tree, anims, names, 30); err != nil {
+		t.Fatal(err)
+	}
+	maxErr, err := gltfRoundTripError(filename, mesh, tree, anims, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxErr > 1e-3 {
+		t.Errorf("%s reads back with vertices up to %v away", filename, maxErr)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sample times of every clip in the golden images
+var goldenTimes = []float64{0.0, 0.75, 1.5, 2.25}
+
+const (
+	goldenWidth  = 160
+	goldenHeight = 120
+)
+
+// GoldenOptions configures a run of the golden image comparison
+type GoldenOptions struct {
+	// asset root the animations, skeleton, mesh and golden directory are
+	// relative to, along with the shaders and default texture
+	Assets     string
+	Animations string
+	Skeleton   string
+	Mesh       string
+	// offscreen backend, see NewOffscreenScene
+	Renderer string
+	// directory of the committed golden images
+	Golden string
+	// directory the diff images of failing frames are written to
+	Diff string
+	// perceptual difference, between 0 and 1, above which a pixel differs
+	Threshold float64
+	// fraction of differing pixels a frame may have and still pass
+	MaxMismatch float64
+	// replace the golden images instead of comparing against them
+	Update bool
+}
+
+type goldenFailure struct {
+	name     string
+	mismatch float64
+}
+
+// RunGolden renders the skinned mesh with every clip of the animations
+// directory at goldenTimes and compares the frames against the golden
+// images. It returns the number of frames rendered, and an error that lists
+// the frames which differ.
+func RunGolden(opts GoldenOptions) (int, error) {
+	opts.Animations = assetPath(opts.Assets, opts.Animations)
+	opts.Skeleton = assetPath(opts.Assets, opts.Skeleton)
+	opts.Mesh = assetPath(opts.Assets, opts.Mesh)
+	opts.Golden = assetPath(opts.Assets, opts.Golden)
+
+	clips, err := filepath.Glob(filepath.Join(opts.Animations, "*.saf"))
+	if err != nil {
+		return 0, err
+	}
+	if len(clips) == 0 {
+		return 0, fmt.Errorf("no .saf clips in %s", opts.Animations)
+	}
+	sort.Strings(clips)
+
+	scene, err := NewOffscreenScene(opts.Renderer, opts.Assets, goldenWidth, goldenHeight, []string{opts.Mesh})
+	if err != nil {
+		return 0, err
+	}
+	defer scene.Delete()
+
+	dir := opts.Diff
+	if opts.Update {
+		dir = opts.Golden
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	failures := make([]goldenFailure, 0)
+	frames := 0
+	for _, clip := range clips {
+		anim := LoadAnimation(clip)
+		// a fresh skeleton for every clip, so spring bones start at rest
+		tree, constraints, err := LoadSkeleton(opts.Skeleton)
+		if err != nil {
+			return 0, err
+		}
+		if err := scene.checkSkin(tree); err != nil {
+			return 0, err
+		}
+		clipName := strings.TrimSuffix(filepath.Base(clip), filepath.Ext(clip))
+
+		for i, img := range scene.renderAnimation(tree, constraints, anim, goldenTimes) {
+			name := fmt.Sprintf("%s_%d.png", clipName, i)
+			golden := filepath.Join(opts.Golden, name)
+			frames++
+
+			if opts.Update {
+				if err := SavePNG(golden, img); err != nil {
+					return 0, err
+				}
+				continue
+			}
+
+			want, err := loadPNG(golden)
+			if err != nil {
+				return 0, err
+			}
+
+			mismatched, diff := compareImages(want, img, opts.Threshold)
+			mismatch := float64(mismatched) / float64(goldenWidth*goldenHeight)
+			if mismatch > opts.MaxMismatch {
+				failures = append(failures, goldenFailure{name, mismatch})
+				if err := SavePNG(filepath.Join(opts.Diff, name), diff); err != nil {
+					return 0, err
+				}
+				if err := SavePNG(filepath.Join(opts.Diff, strings.TrimSuffix(name, ".png")+"_actual.png"), img); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	if opts.Update {
+		return frames, nil
+	}
+	if len(failures) > 0 {
+		lines := make([]string, len(failures))
+		for i, f := range failures {
+			lines[i] = fmt.Sprintf("  %s: %.2f%% of the pixels differ", f.name, f.mismatch*100)
+		}
+		return frames, fmt.Errorf("%d of %d frames differ from the golden images, diffs are in %s:\n%s",
+			len(failures), frames, opts.Diff, strings.Join(lines, "\n"))
+	}
+
+	return frames, nil
+}
+
+func loadPNG(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("golden image %q not found on disk: %v", filename, err)
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+// counts the pixels whose perceptual difference is above the threshold and
+// returns a faded copy of the expected image with those pixels in red
+func compareImages(want, got image.Image, threshold float64) (int, *image.NRGBA) {
+	bounds := want.Bounds()
+	diff := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	mismatched := 0
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			a := want.At(bounds.Min.X+x, bounds.Min.Y+y)
+			var b color.Color = color.Transparent
+			if p := image.Pt(got.Bounds().Min.X+x, got.Bounds().Min.Y+y); p.In(got.Bounds()) {
+				b = got.At(p.X, p.Y)
+			}
+
+			if colorDistance(a, b) > threshold {
+				mismatched++
+				diff.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+				continue
+			}
+
+			brightness, _, _ := yiq(a)
+			gray := uint8(255 - (255-brightness)/4)
+			diff.SetNRGBA(x, y, color.NRGBA{gray, gray, gray, 255})
+		}
+	}
+
+	// a frame of another size fails as a whole
+	if got.Bounds().Dx() != bounds.Dx() || got.Bounds().Dy() != bounds.Dy() {
+		mismatched = bounds.Dx() * bounds.Dy()
+	}
+	return mismatched, diff
+}
+
+// returns the brightness and chroma of a color blended over black
+func yiq(c color.Color) (float64, float64, float64) {
+	r, g, b, _ := c.RGBA()
+	rf, gf, bf := float64(r)/257, float64(g)/257, float64(b)/257
+
+	y := 0.29889531*rf + 0.58662247*gf + 0.11448223*bf
+	i := 0.59597799*rf - 0.27417610*gf - 0.32180189*bf
+	q := 0.21147017*rf - 0.52261711*gf + 0.31114694*bf
+	return y, i, q
+}
+
+// perceptual difference between two colors in YIQ space, weighted like the
+// eye weighs brightness and chroma; 0 for equal colors, 1 for black and white
+func colorDistance(a, b color.Color) float64 {
+	// largest possible weighted distance, between black and white
+	const maxDelta = 35215.0
+
+	y1, i1, q1 := yiq(a)
+	y2, i2, q2 := yiq(b)
+	dy, di, dq := y1-y2, i1-i2, q1-q2
+
+	return (0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq) / maxDelta
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+// the software rasterizer needs no GPU, so the golden images are checked
+// on every test run
+func TestGoldenSoftware(t *testing.T) {
+	frames, err := RunGolden(GoldenOptions{
+		Animations:  "resources/animations",
+		Skeleton:    "resources/skeletons/cube.sks",
+		Mesh:        "resources/models/cube.obj",
+		Renderer:    "software",
+		Golden:      "resources/golden",
+		Diff:        t.TempDir(),
+		Threshold:   0.1,
+		MaxMismatch: 0.005,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames == 0 {
+		t.Error("no frames rendered")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// GPUMesh is a Mesh uploaded to a vertex array with its own vertex and
+// element buffers, ready to be drawn with the program it was set up for
+type GPUMesh struct {
+	vao   uint32
+	vbo   uint32
+	ebo   uint32
+	count int32
+	Parts []MeshPart
+}
+
+func NewGPUMesh(mesh Mesh, program uint32) (GPUMesh, error) {
+	m := GPUMesh{count: int32(len(mesh.Indices)), Parts: mesh.Parts}
+
+	gl.GenVertexArrays(1, &m.vao)
+	gl.BindVertexArray(m.vao)
+
+	vertices := mesh.vertexData()
+	gl.GenBuffers(1, &m.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STATIC_DRAW)
+
+	// the element buffer binding is stored in the vertex array
+	gl.GenBuffers(1, &m.ebo)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.ebo)
+	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(mesh.Indices)*4, gl.Ptr(mesh.Indices), gl.STATIC_DRAW)
+
+	err := meshLayout.bind(program)
+	gl.BindVertexArray(0)
+	if err != nil {
+		m.Delete()
+		return GPUMesh{}, err
+	}
+	return m, nil
+}
+
+func (m GPUMesh) draw() {
+	gl.BindVertexArray(m.vao)
+	gl.DrawElements(gl.TRIANGLES, m.count, gl.UNSIGNED_INT, gl.PtrOffset(0))
+}
+
+// draws the indices of a single part of the mesh
+func (m GPUMesh) drawPart(part MeshPart) {
+	gl.BindVertexArray(m.vao)
+	gl.DrawElements(gl.TRIANGLES, int32(part.Count), gl.UNSIGNED_INT, gl.PtrOffset(part.First*4))
+}
+
+// releases the GL objects of the mesh, which cannot be drawn afterwards
+func (m *GPUMesh) Delete() {
+	gl.DeleteVertexArrays(1, &(*m).vao)
+	gl.DeleteBuffers(1, &(*m).vbo)
+	gl.DeleteBuffers(1, &(*m).ebo)
+	(*m).vao, (*m).vbo, (*m).ebo = 0, 0, 0
+	(*m).count = 0
+}
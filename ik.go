@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// TwoBoneIK bends a chain of three nodes so the end node reaches the target.
+// It is meant to run on a tree that was already posed by Animation.animate.
+type TwoBoneIK struct {
+	Root   int
+	Mid    int
+	End    int
+	Target mgl32.Vec3
+	// optional point the middle joint bends towards
+	Pole *mgl32.Vec3
+	// 0 keeps the animated pose, 1 fully reaches the target
+	Weight float32
+}
+
+func NewTwoBoneIK(t AnimationTree, root, mid, end int) (TwoBoneIK, error) {
+	if !t.isChain(root, mid, end) {
+		return TwoBoneIK{}, fmt.Errorf("nodes %d, %d, %d do not form a chain", root, mid, end)
+	}
+
+	return TwoBoneIK{Root: root, Mid: mid, End: end, Weight: 1.0}, nil
+}
+
+func (ik TwoBoneIK) apply(t AnimationTree) AnimationTree {
+	root := t.Nodes[ik.Root]
+	mid := t.Nodes[ik.Mid]
+	end := t.Nodes[ik.End]
+
+	// solve in the joint space of the root, the shader applies the scale and
+	// the Y rotation afterwards
+	a := mgl32.Vec3(root.jointPosition())
+	b := mgl32.Vec3(mid.jointPosition())
+	c := mgl32.Vec3(end.jointPosition())
+	target := root.toJointSpace(ik.Target)
+
+	lab := b.Sub(a).Len()
+	lbc := c.Sub(b).Len()
+	if lab < epsilon || lbc < epsilon {
+		return t
+	}
+
+	toTarget := target.Sub(a)
+	dist := mgl32.Clamp(toTarget.Len(), float32(math.Abs(float64(lab-lbc)))+epsilon, lab+lbc-epsilon)
+	dir := toTarget.Normalize()
+	if toTarget.Len() < epsilon {
+		dir = c.Sub(a).Normalize()
+	}
+
+	// the middle joint stays in the plane holding the target and the pole,
+	// or the current bend of the chain when there is no pole
+	bend := b.Sub(a)
+	if ik.Pole != nil {
+		bend = root.toJointSpace(*ik.Pole).Sub(a)
+	}
+	bend = bend.Sub(dir.Mul(bend.Dot(dir)))
+	if bend.Len() < epsilon {
+		bend = perpendicular(dir)
+	}
+	bend = bend.Normalize()
+
+	cosA := mgl32.Clamp((lab*lab+dist*dist-lbc*lbc)/(2*lab*dist), -1, 1)
+	sinA := float32(math.Sqrt(float64(1 - cosA*cosA)))
+	newB := a.Add(dir.Mul(cosA * lab)).Add(bend.Mul(sinA * lab))
+	newC := a.Add(dir.Mul(dist))
+
+	root.rotate(weighted(mgl32.QuatBetweenVectors(b.Sub(a), newB.Sub(a)), ik.Weight), a)
+
+	b = mgl32.Vec3(mid.jointPosition())
+	c = mgl32.Vec3(end.jointPosition())
+	mid.rotate(weighted(mgl32.QuatBetweenVectors(c.Sub(b), newC.Sub(b)), ik.Weight), b)
+
+	return t
+}
+
+// IKChain holds the settings shared by the iterative solvers
+type IKChain struct {
+	// node indices from the root of the chain to the end effector
+	Nodes         []int
+	Target        mgl32.Vec3
+	MaxIterations int
+	// distance to the target that is considered close enough
+	Tolerance float32
+	// maximum bend in radians at every joint, relative to the parent bone or
+	// to the animated direction for the first joint; 0 leaves it unconstrained
+	Limits []float32
+	// 0 keeps the animated pose, 1 fully applies the solution
+	Weight float32
+}
+
+func newIKChain(t AnimationTree, nodes []int) (IKChain, error) {
+	if len(nodes) < 2 {
+		return IKChain{}, fmt.Errorf("an IK chain needs at least two nodes, got %d", len(nodes))
+	}
+	if !t.isChain(nodes...) {
+		return IKChain{}, fmt.Errorf("nodes %v do not form a chain", nodes)
+	}
+
+	return IKChain{
+		Nodes:         nodes,
+		MaxIterations: 10,
+		Tolerance:     1e-3,
+		Limits:        make([]float32, len(nodes)),
+		Weight:        1.0}, nil
+}
+
+// returns the joint positions in the joint space of the chain root and the
+// target mapped to the same space
+func (c IKChain) positions(t AnimationTree) ([]mgl32.Vec3, mgl32.Vec3) {
+	p := make([]mgl32.Vec3, len(c.Nodes))
+	for i, idx := range c.Nodes {
+		p[i] = t.Nodes[idx].jointPosition()
+	}
+	return p, t.Nodes[c.Nodes[0]].toJointSpace(c.Target)
+}
+
+func (c IKChain) limit(i int) float32 {
+	if i < len(c.Limits) {
+		return c.Limits[i]
+	}
+	return 0.0
+}
+
+// rotates the chain nodes one by one so their joints land on the solved
+// positions, scaled down by the chain weight
+func (c IKChain) pose(t AnimationTree, solved []mgl32.Vec3) AnimationTree {
+	for i := 0; i < len(c.Nodes)-1; i++ {
+		joint := mgl32.Vec3(t.Nodes[c.Nodes[i]].jointPosition())
+		next := mgl32.Vec3(t.Nodes[c.Nodes[i+1]].jointPosition())
+
+		rot := mgl32.QuatBetweenVectors(next.Sub(joint), solved[i+1].Sub(joint))
+		t.Nodes[c.Nodes[i]].rotate(weighted(rot, c.Weight), joint)
+	}
+	return t
+}
+
+// FABRIK solves chains of any length with forward and backward reaching
+type FABRIK struct {
+	IKChain
+}
+
+func NewFABRIK(t AnimationTree, nodes ...int) (FABRIK, error) {
+	chain, err := newIKChain(t, nodes)
+	return FABRIK{chain}, err
+}
+
+func (ik FABRIK) apply(t AnimationTree) AnimationTree {
+	p, target := ik.positions(t)
+	n := len(p)
+
+	lengths := make([]float32, n-1)
+	var total float32
+	for i := range lengths {
+		lengths[i] = p[i+1].Sub(p[i]).Len()
+		total += lengths[i]
+	}
+
+	root := p[0]
+	rest := p[1].Sub(p[0])
+
+	// out of reach, stretch the chain towards the target
+	if target.Sub(root).Len() >= total {
+		dir := target.Sub(root).Normalize()
+		for i := 0; i < n-1; i++ {
+			dir = ik.constrain(dir, i, p, rest)
+			p[i+1] = p[i].Add(dir.Mul(lengths[i]))
+		}
+		return ik.pose(t, p)
+	}
+
+	for iter := 0; iter < ik.MaxIterations; iter++ {
+		if p[n-1].Sub(target).Len() <= ik.Tolerance {
+			break
+		}
+
+		// backward: pin the end effector to the target, keeping every bone
+		// within its limit of the parent bone from the previous forward pass
+		p[n-1] = target
+		for i := n - 2; i >= 0; i-- {
+			dir := ik.constrain(p[i+1].Sub(p[i]).Normalize(), i, p, rest)
+			p[i] = p[i+1].Sub(dir.Mul(lengths[i]))
+		}
+
+		// forward: pin the root back in place
+		p[0] = root
+		for i := 0; i < n-1; i++ {
+			dir := ik.constrain(p[i+1].Sub(p[i]).Normalize(), i, p, rest)
+			p[i+1] = p[i].Add(dir.Mul(lengths[i]))
+		}
+	}
+
+	return ik.pose(t, p)
+}
+
+// clamps the direction of the bone starting at joint i to the joint limit
+func (c IKChain) constrain(dir mgl32.Vec3, i int, p []mgl32.Vec3, rest mgl32.Vec3) mgl32.Vec3 {
+	parent := rest
+	if i > 0 {
+		parent = p[i].Sub(p[i-1])
+	}
+	return clampDirection(dir, parent, c.limit(i))
+}
+
+// CCD solves chains of any length with cyclic coordinate descent
+type CCD struct {
+	IKChain
+}
+
+func NewCCD(t AnimationTree, nodes ...int) (CCD, error) {
+	chain, err := newIKChain(t, nodes)
+	return CCD{chain}, err
+}
+
+func (ik CCD) apply(t AnimationTree) AnimationTree {
+	p, target := ik.positions(t)
+	n := len(p)
+	rest := p[1].Sub(p[0])
+
+	for iter := 0; iter < ik.MaxIterations; iter++ {
+		if p[n-1].Sub(target).Len() <= ik.Tolerance {
+			break
+		}
+
+		for i := n - 2; i >= 0; i-- {
+			rot := mgl32.QuatBetweenVectors(p[n-1].Sub(p[i]), target.Sub(p[i]))
+
+			// keep the bone inside the joint limit
+			bone := rot.Rotate(p[i+1].Sub(p[i]))
+			limited := ik.constrain(bone, i, p, rest)
+			rot = mgl32.QuatBetweenVectors(bone, limited).Mul(rot)
+
+			for j := i + 1; j < n; j++ {
+				p[j] = p[i].Add(rot.Rotate(p[j].Sub(p[i])))
+			}
+		}
+	}
+
+	return ik.pose(t, p)
+}
+
+// returns dir rotated towards axis so the angle between them is at most
+// maxAngle radians; a limit of 0 returns dir unchanged
+func clampDirection(dir, axis mgl32.Vec3, maxAngle float32) mgl32.Vec3 {
+	if maxAngle <= 0.0 || dir.Len() < epsilon || axis.Len() < epsilon {
+		return dir
+	}
+
+	length := dir.Len()
+	d := dir.Normalize()
+	a := axis.Normalize()
+	angle := float32(math.Acos(float64(mgl32.Clamp(d.Dot(a), -1, 1))))
+	if angle <= maxAngle {
+		return dir
+	}
+
+	rotAxis := a.Cross(d)
+	if rotAxis.Len() < epsilon {
+		rotAxis = perpendicular(a)
+	}
+	return mgl32.QuatRotate(maxAngle, rotAxis.Normalize()).Rotate(a).Mul(length)
+}
+
+// scales a rotation down towards the identity
+func weighted(rot mgl32.Quat, weight float32) mgl32.Quat {
+	if weight >= 1.0 {
+		return rot
+	}
+	return mgl32.QuatSlerp(mgl32.QuatIdent(), rot, mgl32.Clamp(weight, 0, 1))
+}
+
+const epsilon = 1e-5
+
+// returns a unit vector perpendicular to v
+func perpendicular(v mgl32.Vec3) mgl32.Vec3 {
+	axis := mgl32.Vec3{1.0, 0.0, 0.0}
+	if math.Abs(float64(v.Normalize().Dot(axis))) > 0.9 {
+		axis = mgl32.Vec3{0.0, 0.0, 1.0}
+	}
+	return v.Cross(axis).Normalize()
+}
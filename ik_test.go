@@ -0,0 +1,214 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// the two node tree of the cube extended by a tip node, the chain of the
+// twobone constraint in resources/skeletons/cube_rig.sks
+func cubeChain() AnimationTree {
+	node := NewAnimationNode([3]float32{0.0, -1.0, 0.0})
+	node.addChild([3]float32{0.0, 1.0, 0.0})
+	node.Children[0].addChild([3]float32{0.0, 2.0, 0.0})
+
+	tree := AnimationTree{make([]*AnimationNode, 0), make([]SkinVertex, 0)}
+	tree.addNodes(&node)
+	return tree
+}
+
+func boneLength(t AnimationTree, parent, child int) float32 {
+	return t.Nodes[child].worldPosition().Sub(t.Nodes[parent].worldPosition()).Len()
+}
+
+func TestTwoBoneIKReachesTarget(t *testing.T) {
+	tree := cubeChain()
+	ik, err := NewTwoBoneIK(tree, 0, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []mgl32.Vec3{{1.0, 0.5, 1.0}, {1.5, -1.0, 0.0}, {0.0, 0.5, -2.0}, {-0.5, 0.0, 1.2}}
+	for _, pole := range []*mgl32.Vec3{nil, {0.0, 0.0, 1.0}} {
+		ik.Pole = pole
+		for _, target := range targets {
+			tree.resetTree()
+			ik.Target = target
+			tree = ik.apply(tree)
+
+			if end := tree.Nodes[2].worldPosition(); end.Sub(target).Len() > 1e-3 {
+				t.Errorf("pole %v: end at %v, want %v", pole, end, target)
+			}
+			if l := boneLength(tree, 0, 1); mgl32.Abs(l-2.0) > 1e-3 {
+				t.Errorf("pole %v, target %v: first bone is %v long, want 2", pole, target, l)
+			}
+			if l := boneLength(tree, 1, 2); mgl32.Abs(l-1.0) > 1e-3 {
+				t.Errorf("pole %v, target %v: second bone is %v long, want 1", pole, target, l)
+			}
+		}
+	}
+}
+
+func TestTwoBoneIKBendsTowardsPole(t *testing.T) {
+	tree := cubeChain()
+	ik, _ := NewTwoBoneIK(tree, 0, 1, 2)
+	ik.Target = mgl32.Vec3{0.0, 0.5, 0.0}
+	ik.Pole = &mgl32.Vec3{0.0, 0.0, 1.0}
+	tree = ik.apply(tree)
+
+	if mid := tree.Nodes[1].worldPosition(); mid[2] <= 0.0 {
+		t.Errorf("middle joint at %v, want it bent towards +Z", mid)
+	}
+}
+
+func TestTwoBoneIKOutOfReach(t *testing.T) {
+	tree := cubeChain()
+	ik, _ := NewTwoBoneIK(tree, 0, 1, 2)
+	ik.Target = mgl32.Vec3{10.0, -1.0, 0.0}
+	tree = ik.apply(tree)
+
+	// the chain stretches straight towards the target
+	end := tree.Nodes[2].worldPosition()
+	if end.Sub(mgl32.Vec3{3.0, -1.0, 0.0}).Len() > 1e-2 {
+		t.Errorf("end at %v, want the chain stretched along +X", end)
+	}
+	if l := boneLength(tree, 0, 1) + boneLength(tree, 1, 2); mgl32.Abs(l-3.0) > 1e-3 {
+		t.Errorf("chain is %v long, want 3", l)
+	}
+}
+
+func TestTwoBoneIKWeight(t *testing.T) {
+	tree := cubeChain()
+	ik, _ := NewTwoBoneIK(tree, 0, 1, 2)
+	ik.Target = mgl32.Vec3{1.0, 0.5, 1.0}
+	ik.Weight = 0.0
+	tree = ik.apply(tree)
+
+	if end := tree.Nodes[2].worldPosition(); end.Sub(mgl32.Vec3{0.0, 2.0, 0.0}).Len() > 1e-5 {
+		t.Errorf("end moved to %v with weight 0", end)
+	}
+}
+
+func TestNewTwoBoneIKNeedsChain(t *testing.T) {
+	tree := cubeChain()
+	if _, err := NewTwoBoneIK(tree, 0, 2, 1); err == nil {
+		t.Error("nodes 0, 2, 1 accepted as a chain")
+	}
+	if _, err := NewTwoBoneIK(tree, 0, 1, 3); err == nil {
+		t.Error("missing node 3 accepted in a chain")
+	}
+}
+
+// a straight chain of unit bones from the origin up to (0, bones, 0)
+func straightChain(bones int) (AnimationTree, []int) {
+	root := NewAnimationNode([3]float32{0.0, 0.0, 0.0})
+	node := &root
+	for i := 1; i <= bones; i++ {
+		node.addChild([3]float32{0.0, float32(i), 0.0})
+		node = node.Children[0]
+	}
+
+	tree := AnimationTree{make([]*AnimationNode, 0), make([]SkinVertex, 0)}
+	tree.addNodes(&root)
+	nodes := make([]int, bones+1)
+	for i := range nodes {
+		nodes[i] = i
+	}
+	return tree, nodes
+}
+
+// the iterative solvers share every test case
+var iterativeSolvers = map[string]func(IKChain) Constraint{
+	"FABRIK": func(chain IKChain) Constraint { return FABRIK{chain} },
+	"CCD":    func(chain IKChain) Constraint { return CCD{chain} },
+}
+
+func solve(tree AnimationTree, ik Constraint) mgl32.Vec3 {
+	tree.resetTree()
+	tree = ik.apply(tree)
+	return tree.Nodes[len(tree.Nodes)-1].worldPosition()
+}
+
+func TestIterativeIKReachesTarget(t *testing.T) {
+	tree, nodes := straightChain(3)
+	chain, _ := newIKChain(tree, nodes)
+	chain.MaxIterations = 50
+	chain.Target = mgl32.Vec3{1.0, 1.0, 1.0}
+
+	for name, solver := range iterativeSolvers {
+		if end := solve(tree, solver(chain)); end.Sub(chain.Target).Len() > 1e-2 {
+			t.Errorf("%s: end at %v, want %v", name, end, chain.Target)
+		}
+		for i := 0; i+1 < len(nodes); i++ {
+			if l := boneLength(tree, i, i+1); mgl32.Abs(l-1.0) > 1e-3 {
+				t.Errorf("%s: bone %d is %v long, want 1", name, i, l)
+			}
+		}
+	}
+}
+
+func TestIterativeIKOutOfReach(t *testing.T) {
+	tree, nodes := straightChain(3)
+	chain, _ := newIKChain(tree, nodes)
+	chain.MaxIterations = 50
+	chain.Target = mgl32.Vec3{4.0, 0.0, 0.0}
+
+	for name, solver := range iterativeSolvers {
+		end := solve(tree, solver(chain))
+		if want := (mgl32.Vec3{3.0, 0.0, 0.0}); end.Sub(want).Len() > 1e-2 {
+			t.Errorf("%s: end at %v, want the chain stretched to %v", name, end, want)
+		}
+	}
+}
+
+func TestIterativeIKWeight(t *testing.T) {
+	tree, nodes := straightChain(3)
+	chain, _ := newIKChain(tree, nodes)
+	chain.MaxIterations = 50
+	chain.Target = mgl32.Vec3{1.0, 1.0, 1.0}
+
+	for name, solver := range iterativeSolvers {
+		chain.Weight = 1.0
+		full := solve(tree, solver(chain)).Sub(chain.Target).Len()
+		chain.Weight = 0.5
+		half := solve(tree, solver(chain)).Sub(chain.Target).Len()
+		chain.Weight = 0.0
+		none := solve(tree, solver(chain))
+
+		if none != (mgl32.Vec3{0.0, 3.0, 0.0}) {
+			t.Errorf("%s: weight 0 moves the end to %v", name, none)
+		}
+		if !(full < half && half < mgl32.Vec3{0.0, 3.0, 0.0}.Sub(chain.Target).Len()) {
+			t.Errorf("%s: distances %v at full weight and %v at half weight", name, full, half)
+		}
+	}
+}
+
+func TestIterativeIKJointLimits(t *testing.T) {
+	tree, nodes := straightChain(3)
+	chain, _ := newIKChain(tree, nodes)
+	chain.MaxIterations = 50
+	chain.Target = mgl32.Vec3{1.0, 1.0, 1.0}
+	chain.Limits = []float32{0.3, 0.3, 0.3, 0.3}
+	start := mgl32.Vec3{0.0, 3.0, 0.0}.Sub(chain.Target).Len()
+
+	for name, solver := range iterativeSolvers {
+		end := solve(tree, solver(chain))
+		if d := end.Sub(chain.Target).Len(); d > 1.5 {
+			t.Errorf("%s: end at %v is %v from the target, started %v away", name, end, d, start)
+		}
+
+		// every bone stays within its limit of the one before
+		parent := mgl32.Vec3{0.0, 1.0, 0.0}
+		for i := 0; i+1 < len(nodes); i++ {
+			bone := tree.Nodes[i+1].worldPosition().Sub(tree.Nodes[i].worldPosition())
+			angle := math.Acos(float64(mgl32.Clamp(bone.Normalize().Dot(parent.Normalize()), -1, 1)))
+			if angle > 0.3+1e-3 {
+				t.Errorf("%s: bone %d bends %v rad, limit 0.3", name, i, angle)
+			}
+			parent = bone
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mesh is an indexed triangle list together with the skin weights that tie
+// its vertices to the nodes of an animation tree
+type Mesh struct {
+	Positions [][3]float32
+	UVs       [][2]float32
+	Normals   [][3]float32
+	Skin      []SkinVertex
+	Indices   []uint32
+	// ranges of Indices drawn with each material, empty without materials.
+	// Faces before the first usemtl form a part with the default material.
+	Parts []MeshPart
+}
+
+// Material is the part of an MTL material the renderers use; an empty
+// DiffuseMap draws with the renderer's default texture
+type Material struct {
+	Name string
+	// texture path, already joined with the directory of the MTL file
+	DiffuseMap string
+}
+
+type MeshPart struct {
+	Material Material
+	First    int
+	Count    int
+}
+
+type objVertex struct {
+	position, uv, normal int
+}
+
+// LoadOBJ reads a Wavefront OBJ file and the MTL libraries it references.
+// Polygons are split into triangle fans and every distinct combination of
+// position, uv and normal becomes one vertex of the index buffer. Skin
+// weights use an extra statement other tools ignore:
+//
+//	vw <vertex> <node> <weight> [<node> <weight>...]
+//
+// The nodes are checked against a skeleton by Mesh.checkSkin.
+func LoadOBJ(filename string) (Mesh, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Mesh{}, fmt.Errorf("mesh %q not found on disk: %v", filename, err)
+	}
+	defer file.Close()
+
+	var mesh Mesh
+	var positions, normals [][3]float32
+	var uvs [][2]float32
+	weights := make(map[int]map[int]float32)
+	vertices := make(map[objVertex]uint32)
+	sources := make([]int, 0)
+	materials := make(map[string]Material)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+			continue
+		}
+
+		switch words[0] {
+		case "v", "vn":
+			v, err := parseFloats(words[1:])
+			if err != nil || len(v) < 3 {
+				return Mesh{}, fmt.Errorf("%s:%d: %s needs three coordinates", filename, line, words[0])
+			}
+			if words[0] == "v" {
+				positions = append(positions, [3]float32{v[0], v[1], v[2]})
+			} else {
+				normals = append(normals, [3]float32{v[0], v[1], v[2]})
+			}
+		case "vt":
+			v, err := parseFloats(words[1:])
+			if err != nil || len(v) < 2 {
+				return Mesh{}, fmt.Errorf("%s:%d: vt needs two coordinates", filename, line)
+			}
+			uvs = append(uvs, [2]float32{v[0], v[1]})
+		case "vw":
+			err = parseOBJWeights(words[1:], len(positions), weights)
+		case "f":
+			if len(words) < 4 {
+				return Mesh{}, fmt.Errorf("%s:%d: faces need at least three vertices", filename, line)
+			}
+
+			face := make([]uint32, len(words)-1)
+			for i, word := range words[1:] {
+				var v objVertex
+				if v, err = parseOBJVertex(word, len(positions), len(uvs), len(normals)); err != nil {
+					break
+				}
+
+				idx, ok := vertices[v]
+				if !ok {
+					idx = uint32(len(mesh.Positions))
+					vertices[v] = idx
+					mesh.Positions = append(mesh.Positions, positions[v.position])
+					mesh.UVs = append(mesh.UVs, [2]float32{})
+					mesh.Normals = append(mesh.Normals, [3]float32{})
+					if v.uv >= 0 {
+						mesh.UVs[idx] = uvs[v.uv]
+					}
+					if v.normal >= 0 {
+						mesh.Normals[idx] = normals[v.normal]
+					}
+					sources = append(sources, v.position)
+				}
+				face[i] = idx
+			}
+			if err != nil {
+				break
+			}
+
+			for i := 1; i+1 < len(face); i++ {
+				mesh.Indices = append(mesh.Indices, face[0], face[i], face[i+1])
+			}
+		case "mtllib":
+			for _, lib := range words[1:] {
+				var loaded map[string]Material
+				if loaded, err = LoadMTL(filepath.Join(filepath.Dir(filename), lib)); err != nil {
+					break
+				}
+				for name, m := range loaded {
+					materials[name] = m
+				}
+			}
+		case "usemtl":
+			if len(words) != 2 {
+				return Mesh{}, fmt.Errorf("%s:%d: usemtl needs a material name", filename, line)
+			}
+			m, ok := materials[words[1]]
+			if !ok {
+				return Mesh{}, fmt.Errorf("%s:%d: material %q is not defined", filename, line, words[1])
+			}
+			if len(mesh.Parts) == 0 && len(mesh.Indices) > 0 {
+				mesh.Parts = append(mesh.Parts, MeshPart{Material{}, 0, 0})
+			}
+			mesh.Parts = append(mesh.Parts, MeshPart{m, len(mesh.Indices), 0})
+		default:
+			// objects, groups and smoothing groups do not change the mesh
+		}
+		if err != nil {
+			return Mesh{}, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Mesh{}, err
+	}
+
+	for i := range mesh.Parts {
+		end := len(mesh.Indices)
+		if i+1 < len(mesh.Parts) {
+			end = mesh.Parts[i+1].First
+		}
+		mesh.Parts[i].Count = end - mesh.Parts[i].First
+	}
+
+	// weights belong to positions, so every vertex made from one shares them
+	for idx, position := range sources {
+		if w, ok := weights[position]; ok {
+			sv := SkinVertex{idx, make(map[int]float32)}
+			for node, weight := range w {
+				sv.Weights[node] = weight
+			}
+			mesh.Skin = append(mesh.Skin, sv)
+		}
+	}
+
+	return mesh, nil
+}
+
+// resolves an OBJ index, which counts from 1 or backwards from -1
+func objIndex(word string, count int) (int, error) {
+	idx, err := strconv.Atoi(word)
+	if err != nil {
+		return 0, err
+	}
+	if idx < 0 {
+		idx += count
+	} else {
+		idx--
+	}
+	if idx < 0 || idx >= count {
+		return 0, fmt.Errorf("index %s is out of range", word)
+	}
+	return idx, nil
+}
+
+// parses v, v/vt, v//vn or v/vt/vn; missing parts are -1
+func parseOBJVertex(word string, positions, uvs, normals int) (objVertex, error) {
+	parts := strings.Split(word, "/")
+	if len(parts) > 3 {
+		return objVertex{}, fmt.Errorf("vertex %q has too many parts", word)
+	}
+
+	v := objVertex{-1, -1, -1}
+	var err error
+	if v.position, err = objIndex(parts[0], positions); err != nil {
+		return objVertex{}, err
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if v.uv, err = objIndex(parts[1], uvs); err != nil {
+			return objVertex{}, err
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if v.normal, err = objIndex(parts[2], normals); err != nil {
+			return objVertex{}, err
+		}
+	}
+	return v, nil
+}
+
+func parseOBJWeights(words []string, positions int, weights map[int]map[int]float32) error {
+	if len(words) < 3 || len(words)%2 != 1 {
+		return fmt.Errorf("vw needs a vertex and node weight pairs")
+	}
+
+	position, err := objIndex(words[0], positions)
+	if err != nil {
+		return err
+	}
+
+	w := make(map[int]float32)
+	for i := 1; i < len(words); i += 2 {
+		node, err := strconv.Atoi(words[i])
+		if err != nil {
+			return err
+		}
+		weight, err := strconv.ParseFloat(words[i+1], 32)
+		if err != nil {
+			return err
+		}
+		w[node] += float32(weight)
+	}
+	weights[position] = w
+
+	return nil
+}
+
+// LoadMTL reads the materials of a Wavefront MTL library. Only the diffuse
+// texture is kept, colors and the other maps are ignored.
+func LoadMTL(filename string) (map[string]Material, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("material library %q not found on disk: %v", filename, err)
+	}
+	defer file.Close()
+
+	materials := make(map[string]Material)
+	var current *Material
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+			continue
+		}
+
+		if words[0] == "newmtl" {
+			if len(words) != 2 {
+				return nil, fmt.Errorf("%s:%d: newmtl needs a name", filename, line)
+			}
+			if current != nil {
+				materials[current.Name] = *current
+			}
+			current = &Material{Name: words[1]}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%s:%d: %s before any newmtl", filename, line, words[0])
+		}
+
+		if words[0] == "map_Kd" {
+			if len(words) < 2 {
+				return nil, fmt.Errorf("%s:%d: map_Kd needs a file", filename, line)
+			}
+			// options come before the file name
+			current.DiffuseMap = filepath.Join(filepath.Dir(filename), words[len(words)-1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		materials[current.Name] = *current
+	}
+	return materials, nil
+}
+
+// returns the two heaviest skin nodes of every vertex, -1 when missing,
+// with weights normalized over the pair
+func (m Mesh) skinAttributes() ([][2]float32, [][2]float32) {
+	nodes := make([][2]float32, len(m.Positions))
+	weights := make([][2]float32, len(m.Positions))
+	for i := range nodes {
+		nodes[i] = [2]float32{-1.0, -1.0}
+	}
+
+	for _, sv := range m.Skin {
+		if sv.VertexIdx < 0 || sv.VertexIdx >= len(m.Positions) {
+			continue
+		}
+
+		heaviest := make([]int, 0, len(sv.Weights))
+		for node, w := range sv.Weights {
+			if w > 0.0 {
+				heaviest = append(heaviest, node)
+			}
+		}
+		sort.Slice(heaviest, func(a, b int) bool {
+			if sv.Weights[heaviest[a]] != sv.Weights[heaviest[b]] {
+				return sv.Weights[heaviest[a]] > sv.Weights[heaviest[b]]
+			}
+			return heaviest[a] < heaviest[b]
+		})
+		if len(heaviest) > 2 {
+			heaviest = heaviest[:2]
+		}
+
+		var sum float32
+		for _, node := range heaviest {
+			sum += sv.Weights[node]
+		}
+		for c, node := range heaviest {
+			nodes[sv.VertexIdx][c] = float32(node)
+			weights[sv.VertexIdx][c] = sv.Weights[node] / sum
+		}
+	}
+	return nodes, weights
+}
+
+// checks that every skin weight names a node of the tree the mesh is
+// paired with and that the shaders have a uniform for it
+func (m Mesh) checkSkin(t AnimationTree) error {
+	for _, sv := range m.Skin {
+		for node, w := range sv.Weights {
+			if w <= 0.0 {
+				continue
+			}
+			if node < 0 || node >= len(t.Nodes) {
+				return fmt.Errorf("vertex %d is skinned to node %d, the skeleton has %d nodes", sv.VertexIdx, node, len(t.Nodes))
+			}
+			if node >= maxAnimationNodes {
+				return fmt.Errorf("vertex %d is skinned to node %d, at most %d nodes are supported", sv.VertexIdx, node, maxAnimationNodes)
+			}
+		}
+	}
+	return nil
+}
+
+// returns the vertex attributes interleaved as described by meshLayout
+func (m Mesh) vertexData() []float32 {
+	nodes, weights := m.skinAttributes()
+	data := make([]float32, 0, len(m.Positions)*meshLayout.Stride()/4)
+
+	for i, p := range m.Positions {
+		var uv [2]float32
+		var normal [3]float32
+		if i < len(m.UVs) {
+			uv = m.UVs[i]
+		}
+		if i < len(m.Normals) {
+			normal = m.Normals[i]
+		}
+		data = append(data, p[:]...)
+		data = append(data, uv[:]...)
+		data = append(data, normal[:]...)
+		data = append(data, nodes[i][:]...)
+		data = append(data, weights[i][:]...)
+	}
+	return data
+}
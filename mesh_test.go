@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, filename string, lines ...string) {
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadOBJFacesBeforeUsemtl(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "quads.mtl"),
+		"newmtl red",
+		"Kd 1 0 0",
+		"map_Kd textures/red.png")
+	writeFile(t, filepath.Join(dir, "quads.obj"),
+		"mtllib quads.mtl",
+		"v 0 0 0", "v 1 0 0", "v 1 1 0", "v 0 1 0",
+		"f 1 2 3 4",
+		"usemtl red",
+		"f 4 3 2 1")
+
+	mesh, err := LoadOBJ(filepath.Join(dir, "quads.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mesh.Parts) != 2 {
+		t.Fatalf("%d parts, want the default one and red", len(mesh.Parts))
+	}
+	if part := mesh.Parts[0]; part.Material.DiffuseMap != "" || part.First != 0 || part.Count != 6 {
+		t.Errorf("first part %+v, want the default material over 6 indices", part)
+	}
+	red := mesh.Parts[1]
+	if red.First != 6 || red.Count != 6 || red.Material.DiffuseMap != filepath.Join(dir, "textures", "red.png") {
+		t.Errorf("second part %+v, want red over 6 indices with its texture next to the MTL", red)
+	}
+}
+
+func TestLoadOBJWithoutMaterials(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "quad.obj")
+	writeFile(t, filename, "v 0 0 0", "v 1 0 0", "v 1 1 0", "f 1 2 3")
+
+	mesh, err := LoadOBJ(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mesh.Parts) != 0 || len(mesh.Indices) != 3 {
+		t.Errorf("%d parts and %d indices, want none and 3", len(mesh.Parts), len(mesh.Indices))
+	}
+}
+
+func TestCheckSkinRejectsMissingNodes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "quad.obj")
+	writeFile(t, filename, "v 0 0 0", "v 1 0 0", "v 1 1 0", "vw 1 0 1", "vw 3 1 0.5 2 0.5", "f 1 2 3")
+	mesh, err := LoadOBJ(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := AnimationTree{Nodes: make([]*AnimationNode, 3)}
+	if err := mesh.checkSkin(tree); err != nil {
+		t.Errorf("three nodes rejected: %v", err)
+	}
+	tree.Nodes = tree.Nodes[:2]
+	if err := mesh.checkSkin(tree); err == nil {
+		t.Error("skin to node 2 of a two node tree accepted")
+	}
+
+	mesh.Skin[0].Weights = map[int]float32{maxAnimationNodes: 1.0}
+	tree.Nodes = make([]*AnimationNode, maxAnimationNodes+1)
+	if err := mesh.checkSkin(tree); err == nil {
+		t.Errorf("skin to node %d accepted", maxAnimationNodes)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+)
+
+// MirrorAxis is the axis that gets negated, e.g. MirrorX mirrors across the
+// YZ plane and swaps left and right for a character facing Z
+type MirrorAxis int
+
+const (
+	MirrorX MirrorAxis = iota
+	MirrorY
+	MirrorZ
+)
+
+// Mirror returns a copy of the animation where the keys of left and right
+// nodes are swapped and reflected across the plane of the given axis
+func Mirror(anim Animation, t AnimationTree, axis MirrorAxis) Animation {
+	mirrored := Animation{anim.StartTime, anim.TimeStampDuration, make([]AnimationTimeStamp, 0, len(anim.TimeStamps))}
+
+	for _, ts := range anim.TimeStamps {
+		timestamp := AnimationTimeStamp{ts.TimePoint, make([]NodeAnimationTranslation, 0, len(ts.Translations))}
+
+		for _, trans := range ts.Translations {
+			trans.NodeIdx = t.mirrorNode(trans.NodeIdx)
+			trans.Translation[axis] = -trans.Translation[axis]
+
+			// a reflection across a plane containing the Y axis reverses the
+			// direction of rotations around it
+			if axis != MirrorY {
+				trans.RotationY = -trans.RotationY
+			}
+
+			// only the rotation around the mirror axis keeps its direction
+			for c := range trans.Rotation.V {
+				if c != int(axis) {
+					trans.Rotation.V[c] = -trans.Rotation.V[c]
+				}
+			}
+
+			timestamp.Translations = append(timestamp.Translations, trans)
+		}
+
+		mirrored.TimeStamps = append(mirrored.TimeStamps, timestamp)
+	}
+
+	return mirrored
+}
+
+// returns the index of the node on the other side, or the node itself when it
+// has no counterpart
+func (t AnimationTree) mirrorNode(idx int) int {
+	if idx < 0 || idx >= len(t.Nodes) {
+		return idx
+	}
+
+	name := mirrorName(t.Nodes[idx].Name)
+	if name == t.Nodes[idx].Name {
+		return idx
+	}
+	if other := t.findNode(name); other >= 0 {
+		return other
+	}
+	return idx
+}
+
+var mirrorWords = [][2]string{
+	{"left", "right"},
+	{"Left", "Right"},
+	{"LEFT", "RIGHT"},
+}
+
+var mirrorAffixes = [][2]string{
+	{"l", "r"},
+	{"L", "R"},
+}
+
+var mirrorSeparators = []string{"_", ".", "-"}
+
+// swaps the side in names like "LeftArm", "upperLeftArm", "left_arm",
+// "arm_l", "L.arm" or "leg.R". Sides only match whole words, so "cleft"
+// and "leftover" keep their names.
+func mirrorName(name string) string {
+	for _, pair := range mirrorWords {
+		for i, word := range pair {
+			for from := 0; from < len(name); {
+				at := strings.Index(name[from:], word)
+				if at < 0 {
+					break
+				}
+				at += from
+				if isWordAt(name, at, at+len(word)) {
+					return name[:at] + pair[1-i] + name[at+len(word):]
+				}
+				from = at + 1
+			}
+		}
+	}
+
+	for _, pair := range mirrorAffixes {
+		for i, side := range pair {
+			for _, sep := range mirrorSeparators {
+				if strings.HasSuffix(name, sep+side) {
+					return strings.TrimSuffix(name, side) + pair[1-i]
+				}
+				if strings.HasPrefix(name, side+sep) {
+					return pair[1-i] + strings.TrimPrefix(name, side)
+				}
+			}
+		}
+	}
+
+	return name
+}
+
+// reports whether name[start:end] is a word of its own: it begins the name,
+// follows a separator or starts a camel case word, and ends the name, comes
+// before a separator or digit, or before the next camel case word
+func isWordAt(name string, start, end int) bool {
+	isSeparator := func(c byte) bool {
+		return strings.IndexByte("_.- ", c) >= 0
+	}
+	isUpper := func(c byte) bool { return c >= 'A' && c <= 'Z' }
+	isLower := func(c byte) bool { return c >= 'a' && c <= 'z' }
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	first := name[start]
+	startsWord := start == 0 || isSeparator(name[start-1]) ||
+		(isUpper(first) && (isLower(name[start-1]) || isDigit(name[start-1])))
+
+	endsWord := end == len(name) || isSeparator(name[end]) || isDigit(name[end]) ||
+		(isUpper(name[end]) && !isUpper(name[end-1]))
+
+	return startsWord && endsWord
+}
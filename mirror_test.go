@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestMirrorSidestep(t *testing.T) {
+	tree, _, err := LoadSkeleton("resources/skeletons/cube.sks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim := LoadAnimation("resources/animations/sidestep.saf")
+	mirrored := Mirror(anim, tree, MirrorX)
+
+	if len(mirrored.TimeStamps) != len(anim.TimeStamps) {
+		t.Fatalf("%d timestamps, want %d", len(mirrored.TimeStamps), len(anim.TimeStamps))
+	}
+	for i, ts := range mirrored.TimeStamps {
+		key := ts.Translations[0]
+		original := anim.TimeStamps[i].Translations[0]
+		want := [3]float32{-original.Translation[0], original.Translation[1], original.Translation[2]}
+		if key.NodeIdx != original.NodeIdx || key.Translation != want {
+			t.Errorf("timestamp %d keys node %d at %v, want node %d at %v",
+				i, key.NodeIdx, key.Translation, original.NodeIdx, want)
+		}
+	}
+}
+
+func TestMirrorSwapsSidesAndReflectsRotations(t *testing.T) {
+	tree, _, err := LoadSkeleton(writeSkeleton(t,
+		"node hips -1 0 0 0",
+		"node leg_l 0 0.5 0 0",
+		"node leg_r 0 -0.5 0 0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sidestep driven by the left leg, turning and twisting as it steps
+	anim := LoadAnimation("resources/animations/sidestep.saf")
+	up := mgl32.Vec3{0.0, 1.0, 0.0}
+	forward := mgl32.Vec3{1.0, 0.0, 0.0}
+	for i := range anim.TimeStamps {
+		key := &anim.TimeStamps[i].Translations[0]
+		key.NodeIdx = 1
+		key.RotationY = 0.25 * float32(i)
+		key.Rotation = mgl32.QuatRotate(0.5, up).Mul(mgl32.QuatRotate(0.3, forward))
+	}
+	mirrored := Mirror(anim, tree, MirrorX)
+
+	for i, ts := range mirrored.TimeStamps {
+		key := ts.Translations[0]
+		if key.NodeIdx != 2 {
+			t.Errorf("timestamp %d keys node %d, want the right leg", i, key.NodeIdx)
+		}
+		if key.RotationY != -0.25*float32(i) {
+			t.Errorf("timestamp %d turns %v, want %v", i, key.RotationY, -0.25*float32(i))
+		}
+		// turns around Y reverse, rolls around the mirror axis do not
+		want := mgl32.QuatRotate(-0.5, up).Mul(mgl32.QuatRotate(0.3, forward))
+		if !key.Rotation.OrientationEqualThreshold(want, 1e-4) {
+			t.Errorf("timestamp %d rotation %v, want %v", i, key.Rotation, want)
+		}
+	}
+}
+
+func TestMirrorName(t *testing.T) {
+	for name, want := range map[string]string{
+		"LeftArm":      "RightArm",
+		"upperLeftArm": "upperRightArm",
+		"left_arm":     "right_arm",
+		"RIGHT_HAND":   "LEFT_HAND",
+		"hand.left":    "hand.right",
+		"arm_l":        "arm_r",
+		"L.arm":        "R.arm",
+		"leg.R":        "leg.L",
+		"cleft":        "cleft",
+		"leftover":     "leftover",
+		"Leftover":     "Leftover",
+		"spine":        "spine",
+	} {
+		if got := mirrorName(name); got != want {
+			t.Errorf("%s mirrors to %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// Framebuffer is an offscreen render target with a color and a depth
+// attachment, so frames can be rendered without showing a window
+type Framebuffer struct {
+	Width  int
+	Height int
+
+	fbo   uint32
+	color uint32
+	depth uint32
+}
+
+func NewFramebuffer(width, height int) (Framebuffer, error) {
+	f := Framebuffer{Width: width, Height: height}
+
+	gl.GenFramebuffers(1, &f.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.fbo)
+
+	gl.GenRenderbuffers(1, &f.color)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, f.color)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.RGBA8, int32(width), int32(height))
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, f.color)
+
+	gl.GenRenderbuffers(1, &f.depth)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, f.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, int32(width), int32(height))
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, f.depth)
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		f.Delete()
+		return Framebuffer{}, fmt.Errorf("framebuffer is incomplete, status 0x%x", status)
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return f, nil
+}
+
+// makes the framebuffer the target of the following draw calls
+func (f Framebuffer) bind() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.fbo)
+	gl.Viewport(0, 0, int32(f.Width), int32(f.Height))
+}
+
+// reads the color attachment back
+func (f Framebuffer) image() *image.NRGBA {
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, f.fbo)
+	gl.Finish()
+	return readPixels(f.Width, f.Height)
+}
+
+// reads the read framebuffer, flipped so the first row is the top
+func readPixels(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	pixels := make([]uint8, width*height*4)
+
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, int32(width), int32(height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+
+	row := width * 4
+	for y := 0; y < height; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+row], pixels[(height-1-y)*row:(height-y)*row])
+	}
+	return img
+}
+
+func (f *Framebuffer) Delete() {
+	gl.DeleteFramebuffers(1, &(*f).fbo)
+	gl.DeleteRenderbuffers(1, &(*f).color)
+	gl.DeleteRenderbuffers(1, &(*f).depth)
+	(*f).fbo, (*f).color, (*f).depth = 0, 0, 0
+}
+
+func SavePNG(filename string, img image.Image) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// renders with a GLRenderer into a framebuffer instead of a window. By
+// default the context comes from a hidden GLFW window, which needs an X
+// server such as xvfb-run; building with the egl tag creates a surfaceless
+// EGL context instead, which Mesa's llvmpipe provides on machines without a
+// display or a GPU.
+type offscreenGLRenderer struct {
+	*GLRenderer
+	framebuffer Framebuffer
+	context     headlessContext
+}
+
+func newOffscreenGLRenderer(vertexShader, fragmentShader, defaultTexture string, width, height int) (*offscreenGLRenderer, error) {
+	context, err := newHeadlessContext(width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, err := NewGLRenderer(vertexShader, fragmentShader, defaultTexture, width, height)
+	if err != nil {
+		context.destroy()
+		return nil, err
+	}
+
+	framebuffer, err := NewFramebuffer(width, height)
+	if err != nil {
+		renderer.Delete()
+		context.destroy()
+		return nil, err
+	}
+
+	return &offscreenGLRenderer{renderer, framebuffer, context}, nil
+}
+
+func (r *offscreenGLRenderer) draw(frame Frame) {
+	(*r).framebuffer.bind()
+	(*r).GLRenderer.draw(frame)
+}
+
+// replaces the framebuffer with one of the new size
+func (r *offscreenGLRenderer) resize(width, height int) {
+	framebuffer, err := NewFramebuffer(width, height)
+	if err != nil {
+		renderLog.Error("keeping the old framebuffer size", "err", err)
+		return
+	}
+	(*r).framebuffer.Delete()
+	(*r).framebuffer = framebuffer
+	(*r).GLRenderer.resize(width, height)
+}
+
+func (r *offscreenGLRenderer) image() *image.NRGBA {
+	return (*r).framebuffer.image()
+}
+
+func (r *offscreenGLRenderer) Delete() {
+	(*r).framebuffer.Delete()
+	(*r).GLRenderer.Delete()
+	(*r).context.destroy()
+}
+
+// the names accepted by NewOffscreenScene
+var offscreenBackends = []string{"gl", "software"}
+
+// NewOffscreenScene creates a scene that renders to images of the given
+// size, with OpenGL or with the software rasterizer. The shaders and the
+// default texture are looked up under the asset root.
+func NewOffscreenScene(backend, root string, width, height int, meshFiles []string) (*Scene, error) {
+	texture := assetPath(root, "resources/textures/square.png")
+	var renderer Renderer
+	var err error
+	switch backend {
+	case "gl":
+		renderer, err = newOffscreenGLRenderer(assetPath(root, "shaders/test.vs"), assetPath(root, "shaders/test.fs"), texture, width, height)
+	case "software":
+		renderer, err = NewSoftwareRenderer(texture, width, height)
+	default:
+		return nil, fmt.Errorf("unknown renderer %q, expected one of %v", backend, offscreenBackends)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewScene(renderer, width, height, meshFiles)
+}
@@ -0,0 +1,95 @@
+//go:build egl && linux
+
+package main
+
+/*
+#cgo pkg-config: egl
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+static EGLDisplay surfacelessDisplay() {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay =
+		(PFNEGLGETPLATFORMDISPLAYEXTPROC) eglGetProcAddress("eglGetPlatformDisplayEXT");
+	if (getPlatformDisplay != NULL) {
+		EGLDisplay display = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+		if (display != EGL_NO_DISPLAY) {
+			return display;
+		}
+	}
+	return eglGetDisplay(EGL_DEFAULT_DISPLAY);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+type headlessContext struct {
+	display C.EGLDisplay
+	context C.EGLContext
+}
+
+// creates a surfaceless OpenGL 4.1 core context; the framebuffer object is
+// the only render target, so the size is not needed
+func newHeadlessContext(width, height int) (headlessContext, error) {
+	display := C.surfacelessDisplay()
+	if display == C.EGLDisplay(C.EGL_NO_DISPLAY) {
+		return headlessContext{}, fmt.Errorf("no EGL display")
+	}
+	if C.eglInitialize(display, nil, nil) == C.EGL_FALSE {
+		return headlessContext{}, fmt.Errorf("failed to initialize EGL, error 0x%x", C.eglGetError())
+	}
+	if C.eglBindAPI(C.EGL_OPENGL_API) == C.EGL_FALSE {
+		C.eglTerminate(display)
+		return headlessContext{}, fmt.Errorf("EGL does not support desktop OpenGL")
+	}
+
+	// the default surface type asks for windows, which surfaceless
+	// displays do not have
+	configAttribs := []C.EGLint{
+		C.EGL_SURFACE_TYPE, C.EGL_PBUFFER_BIT,
+		C.EGL_RENDERABLE_TYPE, C.EGL_OPENGL_BIT,
+		C.EGL_NONE}
+	var config C.EGLConfig
+	var count C.EGLint
+	if C.eglChooseConfig(display, &configAttribs[0], &config, 1, &count) == C.EGL_FALSE || count == 0 {
+		C.eglTerminate(display)
+		return headlessContext{}, fmt.Errorf("no EGL config supports OpenGL")
+	}
+
+	contextAttribs := []C.EGLint{
+		C.EGL_CONTEXT_MAJOR_VERSION, 4,
+		C.EGL_CONTEXT_MINOR_VERSION, 1,
+		C.EGL_CONTEXT_OPENGL_PROFILE_MASK, C.EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+		C.EGL_NONE}
+	context := C.eglCreateContext(display, config, C.EGLContext(C.EGL_NO_CONTEXT), &contextAttribs[0])
+	if context == C.EGLContext(C.EGL_NO_CONTEXT) {
+		C.eglTerminate(display)
+		return headlessContext{}, fmt.Errorf("failed to create an OpenGL 4.1 context, error 0x%x", C.eglGetError())
+	}
+
+	noSurface := C.EGLSurface(C.EGL_NO_SURFACE)
+	if C.eglMakeCurrent(display, noSurface, noSurface, context) == C.EGL_FALSE {
+		C.eglDestroyContext(display, context)
+		C.eglTerminate(display)
+		return headlessContext{}, fmt.Errorf("failed to make the EGL context current, error 0x%x", C.eglGetError())
+	}
+
+	// Initialize Glow
+	if err := gl.Init(); err != nil {
+		C.eglDestroyContext(display, context)
+		C.eglTerminate(display)
+		return headlessContext{}, err
+	}
+	return headlessContext{display, context}, nil
+}
+
+func (c headlessContext) destroy() {
+	noSurface := C.EGLSurface(C.EGL_NO_SURFACE)
+	C.eglMakeCurrent(c.display, noSurface, noSurface, C.EGLContext(C.EGL_NO_CONTEXT))
+	C.eglDestroyContext(c.display, c.context)
+	C.eglTerminate(c.display)
+}
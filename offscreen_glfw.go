@@ -0,0 +1,31 @@
+//go:build !egl || !linux
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+type headlessContext struct {
+	window *glfw.Window
+}
+
+func newHeadlessContext(width, height int) (headlessContext, error) {
+	if err := glfw.Init(); err != nil {
+		return headlessContext{}, fmt.Errorf("failed to initialize glfw: %v", err)
+	}
+
+	window, err := newContext(width, height, "offscreen", false)
+	if err != nil {
+		glfw.Terminate()
+		return headlessContext{}, err
+	}
+	return headlessContext{window}, nil
+}
+
+func (c headlessContext) destroy() {
+	c.window.Destroy()
+	glfw.Terminate()
+}
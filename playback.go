@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	minPlaybackSpeed = 1.0 / 8.0
+	maxPlaybackSpeed = 8.0
+)
+
+// Playback plays one of a set of clips at a controllable speed, the viewer's
+// keys map onto its methods
+type Playback struct {
+	Clips []Animation
+	Names []string
+	// the file each clip was loaded from
+	Files []string
+	// index of the clip being played
+	Current int
+	// seconds into the current clip, between 0 and its duration
+	Time   float64
+	Speed  float64
+	Paused bool
+	// seconds moved by stepFrame
+	FrameStep float64
+}
+
+// NewPlayback loads the clips in the given order, checking that they only
+// animate nodes of the tree
+func NewPlayback(t AnimationTree, files []string) (*Playback, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no clips to play")
+	}
+
+	p := &Playback{Speed: 1.0, FrameStep: 1.0 / 30.0}
+	for _, file := range files {
+		anim, err := loadAnimation(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := anim.checkNodes(t); err != nil {
+			return nil, fmt.Errorf("%s %v", file, err)
+		}
+		p.Clips = append(p.Clips, anim)
+		p.Names = append(p.Names, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		p.Files = append(p.Files, file)
+	}
+	return p, nil
+}
+
+func (p *Playback) clip() Animation {
+	return (*p).Clips[(*p).Current]
+}
+
+// brings the time back inside the clip, looping in both directions
+func (p *Playback) wrap() {
+	duration := float64(p.clip().duration())
+	if duration <= 0 {
+		(*p).Time = 0
+		return
+	}
+	(*p).Time = math.Mod((*p).Time, duration)
+	if (*p).Time < 0 {
+		(*p).Time += duration
+	}
+}
+
+// moves the clip forward by the elapsed wall clock time, scaled by Speed
+func (p *Playback) advance(elapsed float64) {
+	if (*p).Paused {
+		return
+	}
+	(*p).Time += elapsed * (*p).Speed
+	p.wrap()
+}
+
+func (p *Playback) togglePause() {
+	(*p).Paused = !(*p).Paused
+}
+
+// pauses and moves by whole frames, backwards for negative counts
+func (p *Playback) stepFrame(frames int) {
+	(*p).Paused = true
+	(*p).Time += float64(frames) * (*p).FrameStep
+	p.wrap()
+}
+
+// the time of a keyframe of the current clip
+func (p *Playback) keyframeTime(index int) float64 {
+	clip := p.clip()
+	return float64(clip.TimeStamps[index].TimePoint) * float64(clip.TimeStampDuration)
+}
+
+// index of the last keyframe at or before the current time
+func (p *Playback) keyframeIndex() int {
+	index := 0
+	for i := range p.clip().TimeStamps {
+		// a little slack, so a time set by stepKeyframe counts as on the key
+		if p.keyframeTime(i) <= (*p).Time+1e-6 {
+			index = i
+		}
+	}
+	return index
+}
+
+// pauses on the next keyframe, or the previous one for a negative direction;
+// the last keyframe is the end of the loop, so stepping past it wraps
+func (p *Playback) stepKeyframe(direction int) {
+	(*p).Paused = true
+	count := len(p.clip().TimeStamps)
+	if count == 0 {
+		return
+	}
+
+	index := p.keyframeIndex()
+	if direction < 0 && p.keyframeTime(index) < (*p).Time-1e-6 {
+		// between keys, the previous key is the one just passed
+		(*p).Time = p.keyframeTime(index)
+		return
+	}
+
+	index += direction
+	if index >= count-1 {
+		index = 0
+	}
+	if index < 0 {
+		index = count - 2
+		if index < 0 {
+			index = 0
+		}
+	}
+	(*p).Time = p.keyframeTime(index)
+}
+
+// switches to another clip from its start; out of range indices are ignored
+func (p *Playback) selectClip(index int) {
+	if index < 0 || index >= len((*p).Clips) {
+		return
+	}
+	(*p).Current = index
+	(*p).Time = 0
+}
+
+// multiplies the speed by factor, within minPlaybackSpeed and maxPlaybackSpeed
+func (p *Playback) changeSpeed(factor float64) {
+	(*p).Speed = math.Max(minPlaybackSpeed, math.Min(maxPlaybackSpeed, (*p).Speed*factor))
+}
+
+// poses the tree at the current time of the clip
+func (p *Playback) animate(tree AnimationTree) AnimationTree {
+	clip := p.clip()
+	return clip.animate(tree, clip.StartTime+(*p).Time)
+}
+
+// a line describing the playback state, shown in the window title
+func (p *Playback) status() string {
+	state := ""
+	if (*p).Paused {
+		state = " paused"
+	}
+	return fmt.Sprintf("%d:%s %.2fs / %.2fs key %d/%d x%g%s",
+		(*p).Current+1, (*p).Names[(*p).Current], (*p).Time, p.clip().duration(),
+		p.keyframeIndex(), len(p.clip().TimeStamps)-1, (*p).Speed, state)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewPlaybackChecksClips(t *testing.T) {
+	tree, _, err := LoadSkeleton("resources/skeletons/cube.sks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clips, _ := filepath.Glob("resources/animations/*.saf")
+	playback, err := NewPlayback(tree, clips)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playback.Clips) != len(clips) {
+		t.Errorf("%d clips loaded, want %d", len(playback.Clips), len(clips))
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"missing node":   "ts 0\n5 0 0 0 0 1 1 1\n",
+		"short key":      "ts 0\n0 0 0 0\n",
+		"bad number":     "ts 0\n0 0 x 0 0 1 1 1\n",
+		"key before ts":  "0 0 0 0 0 1 1 1\n",
+		"no time stamps": "duration 0.5\n",
+	} {
+		filename := filepath.Join(dir, "clip.saf")
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewPlayback(tree, []string{filename}); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+
+	if _, err := NewPlayback(tree, []string{filepath.Join(dir, "none.saf")}); err == nil {
+		t.Error("missing clip accepted")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals that ask the viewer for a pose dump, e.g. kill -USR1 <pid>
+var poseDumpSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// windows has no user signals, poses are dumped with the P key only
+var poseDumpSignals = []os.Signal{}
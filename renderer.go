@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Frame is everything a renderer needs to draw the meshes once
+type Frame struct {
+	Projection mgl32.Mat4
+	Camera     mgl32.Mat4
+	Model      mgl32.Mat4
+	// the meshes are skinned to the current pose of the tree
+	Tree AnimationTree
+}
+
+// Renderer draws skinned meshes, on the GPU with GLRenderer or on the CPU
+// with SoftwareRenderer
+type Renderer interface {
+	// prepares a mesh and the textures of its materials for drawing
+	addMesh(mesh Mesh) error
+	// clears the target and draws every added mesh
+	draw(frame Frame)
+	// changes the size of the frames drawn from then on
+	resize(width, height int)
+	// returns the pixels of the last drawn frame
+	image() *image.NRGBA
+	Delete()
+}
+
+// Scene holds the camera and the meshes shown by a renderer; the window
+// loop and the offscreen commands share it whatever the backend is
+type Scene struct {
+	Projection mgl32.Mat4
+	Camera     mgl32.Mat4
+	Model      mgl32.Mat4
+
+	renderer Renderer
+	width    int
+	height   int
+	// the loaded meshes and their files, kept to check them against trees
+	meshes    []Mesh
+	meshFiles []string
+}
+
+// NewScene loads the meshes into the renderer and looks at them from the
+// default camera with the aspect ratio of the given size. The scene owns
+// the renderer from then on, even when loading fails.
+func NewScene(renderer Renderer, width, height int, meshFiles []string) (*Scene, error) {
+	s := &Scene{
+		Projection: perspective(width, height),
+		Camera:     mgl32.LookAtV(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0}),
+		Model:      mgl32.Ident4(),
+		renderer:   renderer,
+		width:      width,
+		height:     height,
+	}
+
+	for _, filename := range meshFiles {
+		mesh, err := LoadOBJ(filename)
+		if err != nil {
+			renderer.Delete()
+			return nil, err
+		}
+		if err := renderer.addMesh(mesh); err != nil {
+			renderer.Delete()
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		(*s).meshes = append((*s).meshes, mesh)
+		(*s).meshFiles = append((*s).meshFiles, filename)
+	}
+	return s, nil
+}
+
+// checks that the meshes of the scene are only skinned to nodes of the
+// tree, see Mesh.checkSkin
+func (s *Scene) checkSkin(tree AnimationTree) error {
+	for i, mesh := range (*s).meshes {
+		if err := mesh.checkSkin(tree); err != nil {
+			return fmt.Errorf("%s: %v", (*s).meshFiles[i], err)
+		}
+	}
+	return nil
+}
+
+// vertical field of view of the projection, in degrees
+const fieldOfView = 45.0
+
+// the far plane is twice the furthest the camera zooms out, so framing a
+// large skeleton in a narrow window does not clip it
+const farPlane = 2 * maxCameraDistance
+
+// the default projection for frames of the given size
+func perspective(width, height int) mgl32.Mat4 {
+	return mgl32.Perspective(mgl32.DegToRad(fieldOfView), float32(width)/float32(height), 0.1, farPlane)
+}
+
+// resizes the renderer and keeps the projection's aspect ratio in step;
+// a zero size, as reported for minimized windows, is ignored
+func (s *Scene) resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	(*s).Projection = perspective(width, height)
+	(*s).width, (*s).height = width, height
+	(*s).renderer.resize(width, height)
+}
+
+// width over height of the frames
+func (s *Scene) aspect() float32 {
+	return float32((*s).width) / float32((*s).height)
+}
+
+// the frame showing the tree from the camera of the scene
+func (s *Scene) frame(tree AnimationTree) Frame {
+	return Frame{(*s).Projection, (*s).Camera, (*s).Model, tree}
+}
+
+// draws every mesh skinned to the current pose of the tree
+func (s *Scene) draw(tree AnimationTree) {
+	(*s).renderer.draw(s.frame(tree))
+}
+
+// draws the tree in its current pose and returns the picture
+func (s *Scene) render(tree AnimationTree) *image.NRGBA {
+	s.draw(tree)
+	return (*s).renderer.image()
+}
+
+// renders the animation at each of the given times, with the constraints
+// of the skeleton applied after sampling like in the viewer
+func (s *Scene) renderAnimation(tree AnimationTree, constraints []Constraint, anim Animation, times []float64) []*image.NRGBA {
+	frames := make([]*image.NRGBA, 0, len(times))
+	for _, time := range times {
+		tree.resetTree()
+		frames = append(frames, s.render(applyConstraints(anim.animate(tree, anim.StartTime+time), constraints, time)))
+	}
+	tree.resetTree()
+	return frames
+}
+
+// releases the renderer of the scene
+func (s *Scene) Delete() {
+	(*s).renderer.Delete()
+}
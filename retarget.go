@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// NodeMap maps node indices of a source tree to node indices of a target tree
+type NodeMap map[int]int
+
+// MapNodesByName pairs the nodes that have the same name in both trees; when
+// several source nodes share a name only the first one is mapped
+func MapNodesByName(src, dst AnimationTree) NodeMap {
+	m := make(NodeMap)
+	used := make(map[int]bool)
+	for i, node := range src.Nodes {
+		if node.Name == "" {
+			continue
+		}
+		if j := dst.findNode(node.Name); j >= 0 && !used[j] {
+			m[i] = j
+			used[j] = true
+		}
+	}
+	return m
+}
+
+// LoadNodeMap reads a map file with one "<source> <target>" pair per line,
+// where each side is either a node name or a node index. Every node appears
+// at most once on each side, so no target gets the keys of two sources.
+func LoadNodeMap(filename string, src, dst AnimationTree) (NodeMap, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("node map %q not found on disk: %v", filename, err)
+	}
+	defer file.Close()
+
+	m := make(NodeMap)
+	sources := make(map[int]int)
+	targets := make(map[int]int)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
+		if len(words) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a source and a target node", filename, line)
+		}
+
+		from, err := resolveNode(src, words[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: source %v", filename, line, err)
+		}
+		to, err := resolveNode(dst, words[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: target %v", filename, line, err)
+		}
+		if previous, ok := sources[from]; ok {
+			return nil, fmt.Errorf("%s:%d: source %s is already mapped on line %d", filename, line, words[0], previous)
+		}
+		if previous, ok := targets[to]; ok {
+			return nil, fmt.Errorf("%s:%d: target %s is already mapped on line %d", filename, line, words[1], previous)
+		}
+		sources[from] = line
+		targets[to] = line
+		m[from] = to
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func resolveNode(t AnimationTree, word string) (int, error) {
+	if idx, err := strconv.Atoi(word); err == nil {
+		if idx < 0 || idx >= len(t.Nodes) {
+			return 0, fmt.Errorf("node %d is not part of the tree", idx)
+		}
+		return idx, nil
+	}
+
+	idx := t.findNode(word)
+	if idx < 0 {
+		return 0, fmt.Errorf("node %q is not part of the tree", word)
+	}
+	return idx, nil
+}
+
+// Retarget makes an animation authored for the source tree drive the target
+// tree. Translations are scaled by the ratio between the bone lengths of the
+// mapped nodes and keys of unmapped nodes are dropped.
+func Retarget(anim Animation, src, dst AnimationTree, nodes NodeMap) Animation {
+	retargeted := Animation{anim.StartTime, anim.TimeStampDuration, make([]AnimationTimeStamp, 0, len(anim.TimeStamps))}
+
+	for _, ts := range anim.TimeStamps {
+		timestamp := AnimationTimeStamp{ts.TimePoint, make([]NodeAnimationTranslation, 0, len(ts.Translations))}
+
+		for _, trans := range ts.Translations {
+			to, ok := nodes[trans.NodeIdx]
+			if !ok {
+				continue
+			}
+
+			ratio := safeDiv(dst.boneLength(to), src.boneLength(trans.NodeIdx))
+			if ratio == 0.0 {
+				ratio = 1.0
+			}
+
+			trans.NodeIdx = to
+			trans.Translation = mgl32.Vec3(trans.Translation).Mul(ratio)
+			timestamp.Translations = append(timestamp.Translations, trans)
+		}
+
+		retargeted.TimeStamps = append(retargeted.TimeStamps, timestamp)
+	}
+
+	return retargeted
+}
+
+// returns the distance from the node to its parent; root nodes use the
+// length of their first child bone instead
+func (t AnimationTree) boneLength(idx int) float32 {
+	node := t.Nodes[idx]
+	if parent := t.parentOf(idx); parent >= 0 {
+		return mgl32.Vec3(node.Pos).Sub(t.Nodes[parent].Pos).Len()
+	}
+	if len(node.Children) > 0 {
+		return mgl32.Vec3(node.Children[0].Pos).Sub(node.Pos).Len()
+	}
+	return 0.0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// the cube skeleton twice as large, under an extra root so every index moves
+func largeCube(t *testing.T) string {
+	return writeSkeleton(t,
+		"node root -1 0 0 0",
+		"node base 0 0 -2 0",
+		"node top 1 0 2 0")
+}
+
+func TestRetargetByName(t *testing.T) {
+	src, _, err := LoadSkeleton("resources/skeletons/cube.sks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, _, err := LoadSkeleton(largeCube(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := MapNodesByName(src, dst)
+	if len(nodes) != 2 || nodes[0] != 1 || nodes[1] != 2 {
+		t.Fatalf("nodes mapped as %v, want base to 1 and top to 2", nodes)
+	}
+
+	anim := LoadAnimation("resources/animations/bounce.saf")
+	retargeted := Retarget(anim, src, dst, nodes)
+	for i, ts := range retargeted.TimeStamps {
+		if len(ts.Translations) != 1 || ts.Translations[0].NodeIdx != 2 {
+			t.Fatalf("timestamp %d keys %v, want only node 2", i, ts.Translations)
+		}
+		want := anim.TimeStamps[i].Translations[0].Translation[1] * 2
+		if got := ts.Translations[0].Translation[1]; got != want {
+			t.Errorf("timestamp %d moves by %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestLoadNodeMapRejectsDuplicates(t *testing.T) {
+	src, _, _ := LoadSkeleton("resources/skeletons/cube.sks")
+	dst, _, _ := LoadSkeleton(largeCube(t))
+
+	for _, pairs := range []string{"base root\ntop root\n", "0 1\n0 2\n"} {
+		filename := filepath.Join(t.TempDir(), "nodes.map")
+		if err := os.WriteFile(filename, []byte(pairs), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadNodeMap(filename, src, dst); err == nil {
+			t.Errorf("map %q accepted", pairs)
+		}
+	}
+}
+
+func TestRetargetCommand(t *testing.T) {
+	dir := t.TempDir()
+	mapFile := filepath.Join(dir, "nodes.map")
+	if err := os.WriteFile(mapFile, []byte("top 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "bounce.saf")
+
+	err := retargetCommand([]string{"-map", mapFile, "resources/skeletons/cube.sks", largeCube(t),
+		"resources/animations/bounce.saf", out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anim := LoadAnimation(out)
+	if len(anim.TimeStamps) != 3 {
+		t.Fatalf("retargeted clip has %d timestamps, want 3", len(anim.TimeStamps))
+	}
+	for _, ts := range anim.TimeStamps {
+		for _, key := range ts.Translations {
+			if key.NodeIdx != 0 {
+				t.Errorf("key of node %d, want only node 0", key.NodeIdx)
+			}
+		}
+	}
+}
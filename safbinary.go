@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// The binary animation format (.safb) stores one keyframe table per node.
+// A track keeps only the components that change: the translation, Y rotation
+// and scale components that vary are quantised to 16 bits between their
+// minimum and maximum over the track, constant ones are stored once and left
+// out altogether at their rest value. The Y rotation is quantised as an angle
+// like the other components, so turns past half a circle survive. Tracks
+// with joint rotations, like imported motion capture, store them as
+// smallest-three quaternions. All values are little endian:
+//
+//	magic "SAFB", version uint8, time stamp duration float32, track count uint16
+//	per track: node uint16, key count uint16, channel mask uint16,
+//	  min and max float32 of every varying component,
+//	  value float32 of every constant component away from rest,
+//	  then per key: time point uint16, every varying component uint16,
+//	  with a joint rotation the dropped component uint8 and the rest [3]uint16
+var safbMagic = [4]byte{'S', 'A', 'F', 'B'}
+
+const safbVersion = 1
+
+// the scalar components of a key in the order they are stored: translation
+// XYZ, Y rotation and scale XYZ
+const keyComponents = 7
+
+var restComponents = [keyComponents]float32{0.0, 0.0, 0.0, 0.0, 1.0, 1.0, 1.0}
+
+// bits 0 to 6 of the channel mask mark the varying components, bits 8 to 14
+// the constant ones stored in the track header
+const constantChannels = 8
+
+// bit of the channel mask marking the tracks with joint rotations
+const rotationChannel = 1 << 7
+
+type animationTrack struct {
+	NodeIdx int
+	Times   []int
+	Keys    []NodeAnimationTranslation
+}
+
+func (k NodeAnimationTranslation) components() [keyComponents]float32 {
+	return [keyComponents]float32{k.Translation[0], k.Translation[1], k.Translation[2],
+		k.RotationY, k.Scale[0], k.Scale[1], k.Scale[2]}
+}
+
+func (k *NodeAnimationTranslation) setComponents(v [keyComponents]float32) {
+	(*k).Translation = [3]float32{v[0], v[1], v[2]}
+	(*k).RotationY = v[3]
+	(*k).Scale = [3]float32{v[4], v[5], v[6]}
+}
+
+func SaveBinaryAnimation(filename string, anim Animation) error {
+	tracks := animationTracks(anim)
+	if len(tracks) > math.MaxUint16 {
+		return fmt.Errorf("%d animated nodes do not fit the binary format", len(tracks))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := writeValues(w, safbMagic, uint8(safbVersion), anim.TimeStampDuration, uint16(len(tracks))); err != nil {
+		return err
+	}
+	for _, track := range tracks {
+		if err := writeTrack(w, track); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeTrack(w io.Writer, track animationTrack) error {
+	if track.NodeIdx < 0 || track.NodeIdx > math.MaxUint16 {
+		return fmt.Errorf("node %d does not fit the binary format", track.NodeIdx)
+	}
+	if len(track.Keys) > math.MaxUint16 {
+		return fmt.Errorf("node %d has too many keys for the binary format", track.NodeIdx)
+	}
+
+	min, max := track.bounds()
+	var mask uint16
+	ranges := make([]float32, 0)
+	constants := make([]float32, 0)
+	for c := 0; c < keyComponents; c++ {
+		switch {
+		case min[c] != max[c]:
+			mask |= 1 << c
+			ranges = append(ranges, min[c], max[c])
+		case min[c] != restComponents[c]:
+			mask |= 1 << (constantChannels + c)
+			constants = append(constants, min[c])
+		}
+	}
+	for _, key := range track.Keys {
+		if !key.Rotation.ApproxEqual(mgl32.QuatIdent()) {
+			mask |= rotationChannel
+			break
+		}
+	}
+	if err := writeValues(w, uint16(track.NodeIdx), uint16(len(track.Keys)), mask, ranges, constants); err != nil {
+		return err
+	}
+
+	for i, key := range track.Keys {
+		if track.Times[i] < 0 || track.Times[i] > math.MaxUint16 {
+			return fmt.Errorf("time point %d does not fit the binary format", track.Times[i])
+		}
+
+		values := []uint16{uint16(track.Times[i])}
+		v := key.components()
+		for c := 0; c < keyComponents; c++ {
+			if mask&(1<<c) != 0 {
+				values = append(values, quantize(v[c], min[c], max[c]))
+			}
+		}
+		if err := writeValues(w, values); err != nil {
+			return err
+		}
+		if mask&rotationChannel != 0 {
+			largest, rotation := encodeQuat(key.Rotation)
+			if err := writeValues(w, largest, rotation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func LoadBinaryAnimation(filename string) (Animation, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Animation{}, fmt.Errorf("animation %q not found on disk: %v", filename, err)
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	var magic [4]byte
+	var version uint8
+	var anim Animation
+	var trackCount uint16
+	if err := readValues(r, &magic, &version, &anim.TimeStampDuration, &trackCount); err != nil {
+		return Animation{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	if magic != safbMagic {
+		return Animation{}, fmt.Errorf("%s: not a binary animation", filename)
+	}
+	if version != safbVersion {
+		return Animation{}, fmt.Errorf("%s: unsupported version %d", filename, version)
+	}
+
+	tracks := make([]animationTrack, trackCount)
+	for i := range tracks {
+		tracks[i], err = readTrack(r)
+		if err != nil {
+			return Animation{}, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+
+	anim.TimeStamps = animationTimeStamps(tracks)
+	return anim, nil
+}
+
+func readTrack(r io.Reader) (animationTrack, error) {
+	var node, count, mask uint16
+	if err := readValues(r, &node, &count, &mask); err != nil {
+		return animationTrack{}, err
+	}
+
+	var min, max [keyComponents]float32
+	rest := restComponents
+	for c := 0; c < keyComponents; c++ {
+		if mask&(1<<c) != 0 {
+			if err := readValues(r, &min[c], &max[c]); err != nil {
+				return animationTrack{}, err
+			}
+		}
+	}
+	for c := 0; c < keyComponents; c++ {
+		if mask&(1<<(constantChannels+c)) != 0 {
+			if err := readValues(r, &rest[c]); err != nil {
+				return animationTrack{}, err
+			}
+		}
+	}
+
+	track := animationTrack{int(node), make([]int, count), make([]NodeAnimationTranslation, count)}
+	for i := range track.Keys {
+		var timePoint uint16
+		if err := readValues(r, &timePoint); err != nil {
+			return animationTrack{}, err
+		}
+
+		v := rest
+		for c := 0; c < keyComponents; c++ {
+			if mask&(1<<c) != 0 {
+				var q uint16
+				if err := readValues(r, &q); err != nil {
+					return animationTrack{}, err
+				}
+				v[c] = dequantize(q, min[c], max[c])
+			}
+		}
+
+		key := NodeAnimationTranslation{NodeIdx: track.NodeIdx, Rotation: mgl32.QuatIdent()}
+		key.setComponents(v)
+		if mask&rotationChannel != 0 {
+			var largest uint8
+			var rotation [3]uint16
+			if err := readValues(r, &largest, &rotation); err != nil {
+				return animationTrack{}, err
+			}
+			if largest > 3 {
+				return animationTrack{}, fmt.Errorf("invalid rotation component %d", largest)
+			}
+			key.Rotation = decodeQuat(largest, rotation)
+		}
+		track.Times[i] = int(timePoint)
+		track.Keys[i] = key
+	}
+
+	return track, nil
+}
+
+func writeValues(w io.Writer, values ...interface{}) error {
+	for _, v := range values {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readValues(r io.Reader, values ...interface{}) error {
+	for _, v := range values {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splits the timestamps into one track per node, in the order the nodes
+// first appear
+func animationTracks(anim Animation) []animationTrack {
+	tracks := make([]animationTrack, 0)
+	index := make(map[int]int)
+
+	for _, ts := range anim.TimeStamps {
+		for _, trans := range ts.Translations {
+			i, ok := index[trans.NodeIdx]
+			if !ok {
+				i = len(tracks)
+				index[trans.NodeIdx] = i
+				tracks = append(tracks, animationTrack{trans.NodeIdx, make([]int, 0), make([]NodeAnimationTranslation, 0)})
+			}
+			tracks[i].Times = append(tracks[i].Times, ts.TimePoint)
+			tracks[i].Keys = append(tracks[i].Keys, trans)
+		}
+	}
+
+	return tracks
+}
+
+// merges tracks back into timestamps holding every node, interpolating the
+// tracks that have no key at a time point
+func animationTimeStamps(tracks []animationTrack) []AnimationTimeStamp {
+	seen := make(map[int]bool)
+	times := make([]int, 0)
+	for _, track := range tracks {
+		for _, tp := range track.Times {
+			if !seen[tp] {
+				seen[tp] = true
+				times = append(times, tp)
+			}
+		}
+	}
+	sort.Ints(times)
+
+	timestamps := make([]AnimationTimeStamp, len(times))
+	for i, tp := range times {
+		timestamps[i] = AnimationTimeStamp{tp, make([]NodeAnimationTranslation, 0, len(tracks))}
+		for _, track := range tracks {
+			if len(track.Keys) > 0 {
+				timestamps[i].Translations = append(timestamps[i].Translations, track.at(tp))
+			}
+		}
+	}
+
+	return timestamps
+}
+
+// returns the key of the track at a time point, clamped to its first and
+// last keys
+func (t animationTrack) at(timePoint int) NodeAnimationTranslation {
+	next := sort.SearchInts(t.Times, timePoint)
+	if next >= len(t.Times) {
+		return t.Keys[len(t.Keys)-1]
+	}
+	if t.Times[next] == timePoint || next == 0 {
+		return t.Keys[next]
+	}
+
+	prev := next - 1
+	factor := float32(timePoint-t.Times[prev]) / float32(t.Times[next]-t.Times[prev])
+	return NodeAnimationTranslation{
+		t.NodeIdx,
+		vec3Lerp(t.Keys[prev].Translation, t.Keys[next].Translation, factor),
+		lerp(t.Keys[prev].RotationY, t.Keys[next].RotationY, factor),
+		vec3Lerp(t.Keys[prev].Scale, t.Keys[next].Scale, factor),
+		mgl32.QuatSlerp(t.Keys[prev].Rotation, t.Keys[next].Rotation, factor)}
+}
+
+// returns the smallest and largest value of every component over the keys
+func (t animationTrack) bounds() ([keyComponents]float32, [keyComponents]float32) {
+	var min, max [keyComponents]float32
+	for i, key := range t.Keys {
+		v := key.components()
+		for c := range v {
+			if i == 0 || v[c] < min[c] {
+				min[c] = v[c]
+			}
+			if i == 0 || v[c] > max[c] {
+				max[c] = v[c]
+			}
+		}
+	}
+	return min, max
+}
+
+func quantize(v, min, max float32) uint16 {
+	if max <= min {
+		return 0
+	}
+	return uint16(math.Round(float64(mgl32.Clamp((v-min)/(max-min), 0, 1) * math.MaxUint16)))
+}
+
+func dequantize(q uint16, min, max float32) float32 {
+	return min + (max-min)*float32(q)/math.MaxUint16
+}
+
+// the three smallest components of a unit quaternion lie in this range
+const smallestThreeRange = math.Sqrt2 / 2
+
+// stores a quaternion as the index of its largest component and the other
+// three quantised to 16 bits; the sign is flipped so the dropped one is positive
+func encodeQuat(q mgl32.Quat) (uint8, [3]uint16) {
+	q = q.Normalize()
+	c := [4]float32{q.V[0], q.V[1], q.V[2], q.W}
+
+	largest := 0
+	for i := range c {
+		if mgl32.Abs(c[i]) > mgl32.Abs(c[largest]) {
+			largest = i
+		}
+	}
+
+	sign := float32(1.0)
+	if c[largest] < 0 {
+		sign = -1.0
+	}
+
+	var packed [3]uint16
+	j := 0
+	for i := range c {
+		if i == largest {
+			continue
+		}
+		packed[j] = quantize(c[i]*sign, -smallestThreeRange, smallestThreeRange)
+		j++
+	}
+	return uint8(largest), packed
+}
+
+func decodeQuat(largest uint8, packed [3]uint16) mgl32.Quat {
+	var c [4]float32
+	var sum float32
+	j := 0
+	for i := range c {
+		if i == int(largest) {
+			continue
+		}
+		c[i] = dequantize(packed[j], -smallestThreeRange, smallestThreeRange)
+		sum += c[i] * c[i]
+		j++
+	}
+	c[largest] = float32(math.Sqrt(math.Max(0, float64(1-sum))))
+
+	return mgl32.Quat{W: c[3], V: mgl32.Vec3{c[0], c[1], c[2]}}
+}
+
+// returns the largest translation or scale difference and the largest
+// rotation difference in radians between two animations played side by
+// side. Both are sampled at every time point either of them keys, where the
+// difference between their interpolated curves peaks, and the keys are
+// matched by node; a node only one of them animates is compared to its rest
+// pose.
+func animationError(original, other Animation) (float32, float32) {
+	times := make([]float32, 0)
+	for _, anim := range []Animation{original, other} {
+		for _, ts := range anim.TimeStamps {
+			times = append(times, float32(ts.TimePoint)*anim.TimeStampDuration)
+		}
+	}
+
+	var posErr, rotErr float32
+	for _, time := range times {
+		a := sampledKeys(original, time)
+		b := sampledKeys(other, time)
+		for node := range b {
+			if _, ok := a[node]; !ok {
+				a[node] = restKey(node)
+			}
+		}
+
+		for node, key := range a {
+			match, ok := b[node]
+			if !ok {
+				match = restKey(node)
+			}
+			posErr = float32(math.Max(float64(posErr), float64(mgl32.Vec3(key.Translation).Sub(match.Translation).Len())))
+			posErr = float32(math.Max(float64(posErr), float64(mgl32.Vec3(key.Scale).Sub(match.Scale).Len())))
+			rotErr = float32(math.Max(float64(rotErr), float64(mgl32.Abs(key.RotationY-match.RotationY))))
+			rotErr = float32(math.Max(float64(rotErr), float64(quatDistance(key.Rotation, match.Rotation))))
+		}
+	}
+	return posErr, rotErr
+}
+
+// the keys of an animation at a time, clamped to its duration, by node
+func sampledKeys(anim Animation, time float32) map[int]NodeAnimationTranslation {
+	keys := make(map[int]NodeAnimationTranslation)
+	for _, key := range anim.sample(mgl32.Clamp(time, 0, anim.duration())) {
+		keys[key.NodeIdx] = key
+	}
+	return keys
+}
+
+func restKey(node int) NodeAnimationTranslation {
+	return NodeAnimationTranslation{NodeIdx: node, Scale: [3]float32{1.0, 1.0, 1.0}, Rotation: mgl32.QuatIdent()}
+}
+
+// returns the angle of the rotation between two quaternions
+func quatDistance(a, b mgl32.Quat) float32 {
+	d := math.Abs(float64(a.Normalize().Dot(b.Normalize())))
+	return float32(2 * math.Acos(math.Min(d, 1)))
+}
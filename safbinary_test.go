@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// a node turning one full circle in uneven steps
+func turningClip(angles ...float32) Animation {
+	anim := Animation{0.0, 1.0, make([]AnimationTimeStamp, len(angles))}
+	for i, angle := range angles {
+		key := restKey(0)
+		key.RotationY = angle
+		anim.TimeStamps[i] = AnimationTimeStamp{i, []NodeAnimationTranslation{key}}
+	}
+	return anim
+}
+
+func binaryRoundTrip(t *testing.T, anim Animation) Animation {
+	filename := filepath.Join(t.TempDir(), "clip.safb")
+	if err := SaveBinaryAnimation(filename, anim); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBinaryAnimation(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func TestBinaryAnimationKeepsWholeTurns(t *testing.T) {
+	anim := turningClip(0.0, 3.0, 3.5, 2*math.Pi)
+	loaded := binaryRoundTrip(t, anim)
+
+	if got := loaded.sample(1.5)[0].RotationY; mgl32.Abs(got-3.25) > 1e-3 {
+		t.Errorf("plays %v rad at 1.5s, want 3.25", got)
+	}
+	if _, rotErr := animationError(anim, loaded); rotErr > 1e-3 {
+		t.Errorf("rotation error %v after the round trip", rotErr)
+	}
+}
+
+func TestAnimationErrorCountsWholeTurns(t *testing.T) {
+	anim := turningClip(0.0, 3.0, 3.5, 2*math.Pi)
+	wrapped := turningClip(0.0, 3.0, 3.5-2*math.Pi, 0.0)
+
+	if _, rotErr := animationError(anim, wrapped); rotErr < 6.0 {
+		t.Errorf("rotation error %v between a clip and its wrapped angles", rotErr)
+	}
+}
+
+func TestBinaryAnimationIsSmaller(t *testing.T) {
+	clips, err := filepath.Glob("resources/animations/*.saf")
+	if err != nil || len(clips) == 0 {
+		t.Fatalf("no clips: %v", err)
+	}
+
+	for _, clip := range clips {
+		anim := LoadAnimation(clip)
+		filename := filepath.Join(t.TempDir(), "clip.safb")
+		if err := SaveBinaryAnimation(filename, anim); err != nil {
+			t.Fatal(err)
+		}
+
+		text, _ := os.Stat(clip)
+		binary, _ := os.Stat(filename)
+		if binary.Size() >= text.Size() {
+			t.Errorf("%s grows from %d to %d bytes", clip, text.Size(), binary.Size())
+		}
+
+		loaded, err := LoadBinaryAnimation(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if posErr, rotErr := animationError(anim, loaded); posErr > 1e-3 || rotErr > 1e-3 {
+			t.Errorf("%s: errors %v and %v after the round trip", clip, posErr, rotErr)
+		}
+	}
+}
+
+func TestSaveBinaryAnimationLimits(t *testing.T) {
+	anim := turningClip(0.0, 1.0)
+	anim.TimeStamps[0].Translations[0].NodeIdx = math.MaxUint16 + 1
+	anim.TimeStamps[1].Translations[0].NodeIdx = math.MaxUint16 + 1
+
+	if err := SaveBinaryAnimation(filepath.Join(t.TempDir(), "clip.safb"), anim); err == nil {
+		t.Error("node index past 16 bits accepted")
+	}
+}
+
+func TestBinaryAnimationJointRotations(t *testing.T) {
+	anim := turningClip(0.0, 1.0, 0.5)
+	anim.TimeStamps[1].Translations[0].Rotation = mgl32.QuatRotate(1.0, mgl32.Vec3{1.0, 0.0, 0.0})
+	loaded := binaryRoundTrip(t, anim)
+
+	if _, rotErr := animationError(anim, loaded); rotErr > 1e-3 {
+		t.Errorf("rotation error %v after the round trip", rotErr)
+	}
+
+	// without joint rotations the track leaves the channel out
+	plain := turningClip(0.0, 1.0, 0.5)
+	sizes := make([]int64, 0)
+	for _, clip := range []Animation{anim, plain} {
+		filename := filepath.Join(t.TempDir(), "clip.safb")
+		if err := SaveBinaryAnimation(filename, clip); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := os.Stat(filename)
+		sizes = append(sizes, info.Size())
+	}
+	if sizes[0]-sizes[1] != 3*7 {
+		t.Errorf("joint rotations take %d bytes, want 7 per key", sizes[0]-sizes[1])
+	}
+}
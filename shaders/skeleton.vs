@@ -0,0 +1,17 @@
+#version 330
+
+uniform mat4 projection;
+uniform mat4 camera;
+uniform mat4 model;
+uniform float pointSize;
+
+in vec3 vert;
+in vec3 vertColor;
+
+out vec3 fragColor;
+
+void main() {
+    fragColor = vertColor;
+    gl_PointSize = pointSize;
+    gl_Position = projection * camera * model * vec4(vert, 1);
+}
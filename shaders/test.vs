@@ -4,13 +4,15 @@ uniform mat4 projection;
 uniform mat4 camera;
 uniform mat4 model;
 
-uniform vec3 animT[2];
-uniform float animR[2];
-uniform vec3 animS[2];
+uniform vec3 animT[16];
+uniform float animR[16];
+uniform vec3 animS[16];
+uniform mat4 animM[16];
 
 in vec3 vert;
 in vec2 vertTexCoord;
 in vec2 skinAttr;
+in vec2 skinWeights;
 
 out vec2 fragTexCoord;
 
@@ -25,21 +27,25 @@ void main() {
     fragTexCoord = vertTexCoord;
     int skin1 = int(skinAttr[0]);
     int skin2 = int(skinAttr[1]);
+    float w1 = skinWeights[0];
+    float w2 = skinWeights[1];
 
     vec4 aux = vec4(vert, 1);
     float rot = 0.0;
     if (skin1 >= 0 || skin2 >= 0) {
         if (skin1 >= 0 && skin2 >= 0) {
-            aux.x += animT[skin1].x * 0.5 + animT[skin2].x * 0.5;
-            aux.y += animT[skin1].y * 0.5 + animT[skin2].y * 0.5;
-            aux.z += animT[skin1].z * 0.5 + animT[skin2].z * 0.5;
-            rot += animR[skin1] * 0.5 + animR[skin2] * 0.5;
-            aux.x *= animS[skin1].x * 0.5 + animS[skin2].x * 0.5;
-            aux.y *= animS[skin1].y * 0.5 + animS[skin2].y * 0.5;
-            aux.z *= animS[skin1].z * 0.5 + animS[skin2].z * 0.5;
+            aux = animM[skin1] * aux * w1 + animM[skin2] * aux * w2;
+            aux.x += animT[skin1].x * w1 + animT[skin2].x * w2;
+            aux.y += animT[skin1].y * w1 + animT[skin2].y * w2;
+            aux.z += animT[skin1].z * w1 + animT[skin2].z * w2;
+            rot += animR[skin1] * w1 + animR[skin2] * w2;
+            aux.x *= animS[skin1].x * w1 + animS[skin2].x * w2;
+            aux.y *= animS[skin1].y * w1 + animS[skin2].y * w2;
+            aux.z *= animS[skin1].z * w1 + animS[skin2].z * w2;
             
         } else{
             if (skin1 >= 0) {
+                aux = animM[skin1] * aux;
                 aux.x += animT[skin1].x;
                 aux.y += animT[skin1].y;
                 aux.z += animT[skin1].z;
@@ -48,6 +54,7 @@ void main() {
                 aux.y *= animS[skin1].y;
                 aux.z *= animS[skin1].z;
             } else {
+                aux = animM[skin2] * aux;
                 aux.x += animT[skin2].x;
                 aux.y += animT[skin2].y;
                 aux.z += animT[skin2].z;
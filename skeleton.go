@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// LoadSkeleton reads an animation tree and its constraints from a text file.
+// Nodes are indexed in the order they appear, the same indices the .saf
+// files use, and a parent must come before its children:
+//
+//	node <name> <parent index or -1> <x> <y> <z>
+//	aim <node> <target xyz> <forward xyz> <up xyz> <max angle> <weight>
+//	twobone <root> <mid> <end> <target xyz> <weight> [<pole xyz>]
+//	fabrik <max iterations> <tolerance> <weight> <target xyz> <node>:<limit>...
+//	ccd <max iterations> <tolerance> <weight> <target xyz> <node>:<limit>...
+//	spring <node> <stiffness> <damping> <gravity xyz>
+//
+// The spring bones of a file make up one SpringSystem, which runs after the
+// other constraints.
+func LoadSkeleton(filename string) (AnimationTree, []Constraint, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return AnimationTree{}, nil, fmt.Errorf("skeleton %q not found on disk: %v", filename, err)
+	}
+	defer file.Close()
+
+	tree := AnimationTree{make([]*AnimationNode, 0), make([]SkinVertex, 0)}
+	constraints := make([]Constraint, 0)
+	springs := NewSpringSystem(springStep)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
+
+		var c Constraint
+		switch words[0] {
+		case "node":
+			err = parseSkeletonNode(&tree, words[1:])
+		case "aim":
+			c, err = parseAimConstraint(tree, words[1:])
+		case "twobone":
+			c, err = parseTwoBoneIK(tree, words[1:])
+		case "fabrik":
+			var chain IKChain
+			chain, err = parseIKChain(tree, words[1:])
+			c = FABRIK{chain}
+		case "ccd":
+			var chain IKChain
+			chain, err = parseIKChain(tree, words[1:])
+			c = CCD{chain}
+		case "spring":
+			err = parseSpringBone(tree, springs, words[1:])
+		default:
+			err = fmt.Errorf("unknown entry %q", words[0])
+		}
+		if err != nil {
+			return AnimationTree{}, nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		if c != nil {
+			constraints = append(constraints, c)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return AnimationTree{}, nil, err
+	}
+	if len(springs.Bones) > 0 {
+		constraints = append(constraints, springs)
+	}
+
+	return tree, constraints, nil
+}
+
+func SaveSkeleton(filename string, tree AnimationTree, constraints []Constraint) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for i, node := range tree.Nodes {
+		fmt.Fprintf(w, "node %s %d %s\n", skeletonName(node.Name), tree.parentOf(i), formatFloats(node.Pos[:]...))
+	}
+
+	for _, c := range constraints {
+		switch c := c.(type) {
+		case AimConstraint:
+			fmt.Fprintf(w, "aim %d %s %s %s %s\n", c.Node,
+				formatFloats(c.Target[:]...), formatFloats(c.Forward[:]...), formatFloats(c.Up[:]...),
+				formatFloats(c.MaxAngle, c.Weight))
+		case TwoBoneIK:
+			fmt.Fprintf(w, "twobone %d %d %d %s %s", c.Root, c.Mid, c.End,
+				formatFloats(c.Target[:]...), formatFloats(c.Weight))
+			if c.Pole != nil {
+				fmt.Fprintf(w, " %s", formatFloats(c.Pole[:]...))
+			}
+			fmt.Fprintln(w)
+		case FABRIK:
+			fmt.Fprintf(w, "fabrik %s\n", formatIKChain(c.IKChain))
+		case CCD:
+			fmt.Fprintf(w, "ccd %s\n", formatIKChain(c.IKChain))
+		case *SpringSystem:
+			for _, bone := range c.Bones {
+				fmt.Fprintf(w, "spring %d %s %s\n", bone.Node,
+					formatFloats(bone.Stiffness, bone.Damping), formatFloats(bone.Gravity[:]...))
+			}
+		default:
+			return fmt.Errorf("constraint %T cannot be saved", c)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// unnamed nodes are written as "-" so every line keeps the same columns
+func skeletonName(name string) string {
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+func parseSkeletonNode(t *AnimationTree, words []string) error {
+	if len(words) != 5 {
+		return fmt.Errorf("node needs a name, a parent and a position")
+	}
+
+	parent, err := strconv.Atoi(words[1])
+	if err != nil {
+		return err
+	}
+	if parent < -1 {
+		return fmt.Errorf("parent %d should be a node index or -1 for a root", parent)
+	}
+	if parent >= len((*t).Nodes) {
+		return fmt.Errorf("parent %d is not defined before the node", parent)
+	}
+
+	pos, err := parseFloats(words[2:])
+	if err != nil {
+		return err
+	}
+
+	node := NewAnimationNode([3]float32{pos[0], pos[1], pos[2]})
+	if words[0] != "-" {
+		node.Name = words[0]
+	}
+	if parent >= 0 {
+		(*t).Nodes[parent].Children = append((*t).Nodes[parent].Children, &node)
+	}
+	(*t).Nodes = append((*t).Nodes, &node)
+
+	return nil
+}
+
+func parseAimConstraint(t AnimationTree, words []string) (Constraint, error) {
+	if len(words) != 12 {
+		return nil, fmt.Errorf("aim needs a node, target, forward, up, max angle and weight")
+	}
+
+	node, err := strconv.Atoi(words[0])
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewAimConstraint(t, node)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseFloats(words[1:])
+	if err != nil {
+		return nil, err
+	}
+	c.Target = mgl32.Vec3{v[0], v[1], v[2]}
+	c.Forward = mgl32.Vec3{v[3], v[4], v[5]}
+	c.Up = mgl32.Vec3{v[6], v[7], v[8]}
+	c.MaxAngle = v[9]
+	c.Weight = v[10]
+
+	return c, nil
+}
+
+func parseTwoBoneIK(t AnimationTree, words []string) (Constraint, error) {
+	if len(words) != 7 && len(words) != 10 {
+		return nil, fmt.Errorf("twobone needs three nodes, a target, a weight and an optional pole")
+	}
+
+	var nodes [3]int
+	for i := range nodes {
+		idx, err := strconv.Atoi(words[i])
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = idx
+	}
+
+	ik, err := NewTwoBoneIK(t, nodes[0], nodes[1], nodes[2])
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseFloats(words[3:])
+	if err != nil {
+		return nil, err
+	}
+	ik.Target = mgl32.Vec3{v[0], v[1], v[2]}
+	ik.Weight = v[3]
+	if len(v) == 7 {
+		ik.Pole = &mgl32.Vec3{v[4], v[5], v[6]}
+	}
+
+	return ik, nil
+}
+
+func parseSpringBone(t AnimationTree, springs *SpringSystem, words []string) error {
+	if len(words) != 6 {
+		return fmt.Errorf("spring needs a node, stiffness, damping and gravity")
+	}
+
+	node, err := strconv.Atoi(words[0])
+	if err != nil {
+		return err
+	}
+	if node < 0 || node >= len(t.Nodes) {
+		return fmt.Errorf("node %d is not part of the tree", node)
+	}
+
+	v, err := parseFloats(words[1:])
+	if err != nil {
+		return err
+	}
+	springs.addBone(node, v[0], v[1], mgl32.Vec3{v[2], v[3], v[4]})
+
+	return nil
+}
+
+func parseIKChain(t AnimationTree, words []string) (IKChain, error) {
+	if len(words) < 8 {
+		return IKChain{}, fmt.Errorf("chain needs iterations, tolerance, weight, target and at least two nodes")
+	}
+
+	iterations, err := strconv.Atoi(words[0])
+	if err != nil {
+		return IKChain{}, err
+	}
+
+	v, err := parseFloats(words[1:6])
+	if err != nil {
+		return IKChain{}, err
+	}
+
+	nodes := make([]int, 0)
+	limits := make([]float32, 0)
+	for _, word := range words[6:] {
+		parts := strings.SplitN(word, ":", 2)
+		if len(parts) != 2 {
+			return IKChain{}, fmt.Errorf("chain node %q should be <node>:<limit>", word)
+		}
+
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return IKChain{}, err
+		}
+		limit, err := strconv.ParseFloat(parts[1], 32)
+		if err != nil {
+			return IKChain{}, err
+		}
+		nodes = append(nodes, idx)
+		limits = append(limits, float32(limit))
+	}
+
+	chain, err := newIKChain(t, nodes)
+	if err != nil {
+		return IKChain{}, err
+	}
+	chain.MaxIterations = iterations
+	chain.Tolerance = v[0]
+	chain.Weight = v[1]
+	chain.Target = mgl32.Vec3{v[2], v[3], v[4]}
+	chain.Limits = limits
+
+	return chain, nil
+}
+
+func formatIKChain(c IKChain) string {
+	words := []string{strconv.Itoa(c.MaxIterations),
+		formatFloats(c.Tolerance, c.Weight),
+		formatFloats(c.Target[:]...)}
+	for i, idx := range c.Nodes {
+		words = append(words, fmt.Sprintf("%d:%s", idx, formatFloats(c.limit(i))))
+	}
+	return strings.Join(words, " ")
+}
+
+func parseFloats(words []string) ([]float32, error) {
+	values := make([]float32, len(words))
+	for i, word := range words {
+		v, err := strconv.ParseFloat(word, 32)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float32(v)
+	}
+	return values, nil
+}
+
+func formatFloats(values ...float32) string {
+	words := make([]string, len(values))
+	for i, v := range values {
+		// avoid writing negative zeros
+		if v == 0.0 {
+			v = 0.0
+		}
+		words[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSkeleton(t *testing.T, lines ...string) string {
+	filename := filepath.Join(t.TempDir(), "test.sks")
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadSkeletonRejectsBadParents(t *testing.T) {
+	for _, parent := range []string{"-5", "-2", "1", "2"} {
+		filename := writeSkeleton(t,
+			"node base -1 0 -1 0",
+			"node top "+parent+" 0 1 0")
+		if _, _, err := LoadSkeleton(filename); err == nil {
+			t.Errorf("parent %s accepted", parent)
+		}
+	}
+}
+
+func TestSaveSkeletonRoundTrip(t *testing.T) {
+	tree, constraints, err := LoadSkeleton("resources/skeletons/cube_rig.sks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "rig.sks")
+	if err := SaveSkeleton(filename, tree, constraints); err != nil {
+		t.Fatal(err)
+	}
+	loaded, loadedConstraints, err := LoadSkeleton(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Nodes) != len(tree.Nodes) || len(loadedConstraints) != len(constraints) {
+		t.Fatalf("saved %d nodes and %d constraints, loaded %d and %d",
+			len(tree.Nodes), len(constraints), len(loaded.Nodes), len(loadedConstraints))
+	}
+	for i, node := range loaded.Nodes {
+		if node.Name != tree.Nodes[i].Name || node.Pos != tree.Nodes[i].Pos || loaded.parentOf(i) != tree.parentOf(i) {
+			t.Errorf("node %d loaded as %s %v under %d", i, node.Name, node.Pos, loaded.parentOf(i))
+		}
+	}
+
+	if _, ok := loadedConstraints[0].(TwoBoneIK); !ok {
+		t.Errorf("first constraint loaded as %T, want TwoBoneIK", loadedConstraints[0])
+	}
+	springs, ok := loadedConstraints[1].(*SpringSystem)
+	if !ok || len(springs.Bones) != 1 || springs.Bones[0].Node != 1 || springs.Bones[0].Stiffness != 150.0 {
+		t.Errorf("second constraint loaded as %#v, want the spring bone of node 1", loadedConstraints[1])
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+var (
+	boneColor     = mgl32.Vec3{0.2, 0.9, 0.3}
+	jointColor    = mgl32.Vec3{1.0, 0.8, 0.1}
+	selectedColor = mgl32.Vec3{1.0, 0.2, 0.2}
+)
+
+// the layout of the debug overlay vertices, a position and a color, as
+// shaders/skeleton.vs reads them
+var colorLayout = NewVertexLayout(
+	VertexAttribute{Name: "vert", Components: 3, Type: gl.FLOAT},
+	VertexAttribute{Name: "vertColor", Components: 3, Type: gl.FLOAT},
+)
+
+// SkeletonOverlay draws the joints of an AnimationTree as points and the
+// links between parents and children as lines, in the same world space as
+// the skinned mesh. It is drawn after the mesh; without DepthTest the whole
+// skeleton stays visible through it.
+type SkeletonOverlay struct {
+	Enabled   bool
+	DepthTest bool
+	PointSize float32
+	// node drawn in selectedColor, -1 for none
+	Selected int
+
+	program uint32
+	vao     uint32
+	vbo     uint32
+
+	projectionUniform int32
+	cameraUniform     int32
+	modelUniform      int32
+	pointSizeUniform  int32
+}
+
+func NewSkeletonOverlay(vertexShader, fragmentShader string) (*SkeletonOverlay, error) {
+	program, err := LoadShaderProgram(vertexShader, fragmentShader)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &SkeletonOverlay{PointSize: 8.0, Selected: -1, program: program}
+	o.projectionUniform = gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	o.cameraUniform = gl.GetUniformLocation(program, gl.Str("camera\x00"))
+	o.modelUniform = gl.GetUniformLocation(program, gl.Str("model\x00"))
+	o.pointSizeUniform = gl.GetUniformLocation(program, gl.Str("pointSize\x00"))
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	gl.GenVertexArrays(1, &o.vao)
+	gl.BindVertexArray(o.vao)
+	gl.GenBuffers(1, &o.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, o.vbo)
+	err = colorLayout.bind(program)
+	gl.BindVertexArray(0)
+	if err != nil {
+		o.Delete()
+		return nil, err
+	}
+	return o, nil
+}
+
+// returns the world position of every joint and the pairs of parent and
+// child indices linked by a bone
+func skeletonLines(tree AnimationTree) ([]mgl32.Vec3, [][2]int) {
+	index := make(map[*AnimationNode]int)
+	for i, node := range tree.Nodes {
+		index[node] = i
+	}
+
+	joints := make([]mgl32.Vec3, len(tree.Nodes))
+	bones := make([][2]int, 0)
+	for i, node := range tree.Nodes {
+		joints[i] = node.worldPosition()
+		for _, child := range node.Children {
+			if j, ok := index[child]; ok {
+				bones = append(bones, [2]int{i, j})
+			}
+		}
+	}
+	return joints, bones
+}
+
+func appendVertex(data []float32, position, color mgl32.Vec3) []float32 {
+	return append(data, position[0], position[1], position[2], color[0], color[1], color[2])
+}
+
+func (o *SkeletonOverlay) toggle() {
+	(*o).Enabled = !(*o).Enabled
+}
+
+// draws the skeleton of the frame's tree into the bound framebuffer
+func (o *SkeletonOverlay) draw(frame Frame) {
+	if !(*o).Enabled || len(frame.Tree.Nodes) == 0 {
+		return
+	}
+
+	// bones first, then joints on top of their ends
+	joints, bones := skeletonLines(frame.Tree)
+	data := make([]float32, 0, (len(bones)*2+len(joints))*6)
+	for _, bone := range bones {
+		data = appendVertex(data, joints[bone[0]], boneColor)
+		data = appendVertex(data, joints[bone[1]], boneColor)
+	}
+	for i, joint := range joints {
+		if i == (*o).Selected {
+			data = appendVertex(data, joint, selectedColor)
+		} else {
+			data = appendVertex(data, joint, jointColor)
+		}
+	}
+
+	gl.UseProgram((*o).program)
+	gl.UniformMatrix4fv((*o).projectionUniform, 1, false, &frame.Projection[0])
+	gl.UniformMatrix4fv((*o).cameraUniform, 1, false, &frame.Camera[0])
+	gl.UniformMatrix4fv((*o).modelUniform, 1, false, &frame.Model[0])
+	gl.Uniform1f((*o).pointSizeUniform, (*o).PointSize)
+
+	gl.BindVertexArray((*o).vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, (*o).vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, gl.Ptr(data), gl.STREAM_DRAW)
+
+	if !(*o).DepthTest {
+		gl.Disable(gl.DEPTH_TEST)
+	}
+	gl.Enable(gl.PROGRAM_POINT_SIZE)
+
+	gl.DrawArrays(gl.LINES, 0, int32(len(bones)*2))
+	gl.DrawArrays(gl.POINTS, int32(len(bones)*2), int32(len(joints)))
+
+	gl.Disable(gl.PROGRAM_POINT_SIZE)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.BindVertexArray(0)
+}
+
+func (o *SkeletonOverlay) Delete() {
+	gl.DeleteBuffers(1, &(*o).vbo)
+	gl.DeleteVertexArrays(1, &(*o).vao)
+	gl.DeleteProgram((*o).program)
+}
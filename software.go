@@ -0,0 +1,326 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// SoftwareRenderer rasterizes the meshes on the CPU the way shaders/test.vs
+// and shaders/test.fs draw them, so the render, capture and golden commands
+// work on machines without OpenGL, and view -renderer software plays the
+// clips in the terminal.
+type SoftwareRenderer struct {
+	Width  int
+	Height int
+
+	color    *image.NRGBA
+	depth    []float32
+	meshes   []softwareMesh
+	textures map[string]*image.RGBA
+}
+
+type softwareMesh struct {
+	mesh    Mesh
+	nodes   [][2]float32
+	weights [][2]float32
+}
+
+// a vertex after the vertex shader
+type clipVertex struct {
+	position mgl32.Vec4
+	uv       mgl32.Vec2
+}
+
+// a vertex in window coordinates, with its attributes divided by w
+type screenVertex struct {
+	x, y, z float32
+	invW    float32
+	uvW     mgl32.Vec2
+}
+
+// the clear color of the GL renderer, 0.1 in every channel
+var softwareClearColor = [4]uint8{26, 26, 26, 255}
+
+func NewSoftwareRenderer(defaultTexture string, width, height int) (*SoftwareRenderer, error) {
+	r := &SoftwareRenderer{
+		Width:    width,
+		Height:   height,
+		color:    image.NewNRGBA(image.Rect(0, 0, width, height)),
+		depth:    make([]float32, width*height),
+		meshes:   make([]softwareMesh, 0),
+		textures: make(map[string]*image.RGBA),
+	}
+
+	if err := r.loadTexture("", defaultTexture); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *SoftwareRenderer) loadTexture(key, filename string) error {
+	if _, ok := (*r).textures[key]; ok {
+		return nil
+	}
+	texture, err := loadTextureImage(filename)
+	if err != nil {
+		return err
+	}
+	(*r).textures[key] = texture
+	return nil
+}
+
+func (r *SoftwareRenderer) addMesh(mesh Mesh) error {
+	for _, part := range mesh.Parts {
+		if err := r.loadTexture(part.Material.DiffuseMap, part.Material.DiffuseMap); err != nil {
+			return err
+		}
+	}
+
+	nodes, weights := mesh.skinAttributes()
+	(*r).meshes = append((*r).meshes, softwareMesh{mesh, nodes, weights})
+	return nil
+}
+
+func (r *SoftwareRenderer) draw(frame Frame) {
+	for i := 0; i < len((*r).color.Pix); i += 4 {
+		copy((*r).color.Pix[i:i+4], softwareClearColor[:])
+	}
+	for i := range (*r).depth {
+		(*r).depth[i] = 1.0
+	}
+
+	t, rot, s := frame.Tree.getAnimation()
+	m := frame.Tree.getJointMatrices()
+	view := frame.Projection.Mul4(frame.Camera).Mul4(frame.Model)
+
+	for _, sm := range (*r).meshes {
+		// CPU skinning, once per vertex
+		vertices := make([]clipVertex, len(sm.mesh.Positions))
+		for i, p := range sm.mesh.Positions {
+			var uv mgl32.Vec2
+			if i < len(sm.mesh.UVs) {
+				uv = sm.mesh.UVs[i]
+			}
+			position := skinVertex(mgl32.Vec3(p), sm.nodes[i], sm.weights[i], t, rot, s, m)
+			vertices[i] = clipVertex{view.Mul4x1(position), uv}
+		}
+
+		parts := sm.mesh.Parts
+		if len(parts) == 0 {
+			parts = []MeshPart{{First: 0, Count: len(sm.mesh.Indices)}}
+		}
+		for _, part := range parts {
+			texture := (*r).textures[part.Material.DiffuseMap]
+			indices := sm.mesh.Indices[part.First : part.First+part.Count]
+			for i := 0; i+2 < len(indices); i += 3 {
+				r.drawTriangle(vertices[indices[i]], vertices[indices[i+1]], vertices[indices[i+2]], texture)
+			}
+		}
+	}
+}
+
+// moves a vertex like the main function of shaders/test.vs, up to the
+// model matrix. Scene.checkSkin rejects meshes skinned to nodes the tree
+// lacks before either renderer sees them; a pose that is still too short
+// leaves the vertex unskinned instead of reading past it.
+func skinVertex(v mgl32.Vec3, nodes, weights [2]float32, t, rot, s, m []float32) mgl32.Vec4 {
+	count := len(rot)
+	for _, c := range []int{len(t) / 3, len(s) / 3, len(m) / 16} {
+		if c < count {
+			count = c
+		}
+	}
+	skin1, skin2 := int(nodes[0]), int(nodes[1])
+	if skin1 >= count {
+		skin1 = -1
+	}
+	if skin2 >= count {
+		skin2 = -1
+	}
+	aux := v.Vec4(1.0)
+	var angle float32
+	if skin1 < 0 && skin2 < 0 {
+		return aux
+	}
+
+	blend := func(values []float32, size, c int) float32 {
+		switch {
+		case skin1 >= 0 && skin2 >= 0:
+			return values[skin1*size+c]*weights[0] + values[skin2*size+c]*weights[1]
+		case skin1 >= 0:
+			return values[skin1*size+c]
+		}
+		return values[skin2*size+c]
+	}
+	matrix := func(node int) mgl32.Mat4 {
+		var joint mgl32.Mat4
+		copy(joint[:], m[node*16:])
+		return joint
+	}
+
+	switch {
+	case skin1 >= 0 && skin2 >= 0:
+		aux = matrix(skin1).Mul4x1(aux).Mul(weights[0]).Add(matrix(skin2).Mul4x1(aux).Mul(weights[1]))
+	case skin1 >= 0:
+		aux = matrix(skin1).Mul4x1(aux)
+	default:
+		aux = matrix(skin2).Mul4x1(aux)
+	}
+	for c := 0; c < 3; c++ {
+		aux[c] += blend(t, 3, c)
+	}
+	angle = blend(rot, 1, 0)
+	for c := 0; c < 3; c++ {
+		aux[c] *= blend(s, 3, c)
+	}
+
+	// the shader's rotationY turns the opposite way to mgl32's
+	return mgl32.HomogRotate3DY(-angle).Mul4x1(aux)
+}
+
+// clips the triangle against the near plane and fills what is left
+func (r *SoftwareRenderer) drawTriangle(a, b, c clipVertex, texture *image.RGBA) {
+	polygon := []clipVertex{a, b, c}
+	clipped := make([]clipVertex, 0, 4)
+
+	// keep the part where z >= -w
+	for i, current := range polygon {
+		next := polygon[(i+1)%len(polygon)]
+		dc := current.position[2] + current.position[3]
+		dn := next.position[2] + next.position[3]
+
+		if dc >= 0 {
+			clipped = append(clipped, current)
+		}
+		if (dc >= 0) != (dn >= 0) {
+			f := dc / (dc - dn)
+			clipped = append(clipped, clipVertex{
+				current.position.Add(next.position.Sub(current.position).Mul(f)),
+				current.uv.Add(next.uv.Sub(current.uv).Mul(f))})
+		}
+	}
+
+	screen := make([]screenVertex, len(clipped))
+	for i, v := range clipped {
+		invW := 1.0 / v.position[3]
+		screen[i] = screenVertex{
+			x:    (v.position[0]*invW + 1.0) * 0.5 * float32((*r).Width),
+			y:    (1.0 - v.position[1]*invW) * 0.5 * float32((*r).Height),
+			z:    (v.position[2]*invW + 1.0) * 0.5,
+			invW: invW,
+			uvW:  v.uv.Mul(invW)}
+	}
+
+	for i := 1; i+1 < len(screen); i++ {
+		r.fillTriangle(screen[0], screen[i], screen[i+1], texture)
+	}
+}
+
+func edge(a, b screenVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// fills the pixels whose centers lie inside the triangle, interpolating
+// depth linearly and texture coordinates with perspective correction
+func (r *SoftwareRenderer) fillTriangle(a, b, c screenVertex, texture *image.RGBA) {
+	area := edge(a, b, c.x, c.y)
+	if area == 0 {
+		return
+	}
+
+	minX := int(math.Max(0, math.Floor(float64(min3(a.x, b.x, c.x)))))
+	maxX := int(math.Min(float64((*r).Width-1), math.Ceil(float64(max3(a.x, b.x, c.x)))))
+	minY := int(math.Max(0, math.Floor(float64(min3(a.y, b.y, c.y)))))
+	maxY := int(math.Min(float64((*r).Height-1), math.Ceil(float64(max3(a.y, b.y, c.y)))))
+
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			px, py := float32(x)+0.5, float32(y)+0.5
+			// barycentric weights, positive inside for either winding
+			wa := edge(b, c, px, py) / area
+			wb := edge(c, a, px, py) / area
+			wc := edge(a, b, px, py) / area
+			if wa < 0 || wb < 0 || wc < 0 {
+				continue
+			}
+
+			z := wa*a.z + wb*b.z + wc*c.z
+			idx := y*(*r).Width + x
+			if z < 0 || z > 1 || z >= (*r).depth[idx] {
+				continue
+			}
+			(*r).depth[idx] = z
+
+			invW := wa*a.invW + wb*b.invW + wc*c.invW
+			uv := a.uvW.Mul(wa).Add(b.uvW.Mul(wb)).Add(c.uvW.Mul(wc)).Mul(1.0 / invW)
+
+			texel := sampleBilinear(texture, uv[0], uv[1])
+			copy((*r).color.Pix[y*(*r).color.Stride+x*4:], texel[:])
+		}
+	}
+}
+
+// samples a texture like GL_LINEAR with GL_CLAMP_TO_EDGE; the first row of
+// the image is at v = 0, as LoadTexture uploads it
+func sampleBilinear(texture *image.RGBA, u, v float32) [4]uint8 {
+	w, h := texture.Rect.Dx(), texture.Rect.Dy()
+	x := float64(u)*float64(w) - 0.5
+	y := float64(v)*float64(h) - 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	clamp := func(i, n int) int {
+		if i < 0 {
+			return 0
+		}
+		if i >= n {
+			return n - 1
+		}
+		return i
+	}
+	texel := func(tx, ty int) []uint8 {
+		offset := clamp(ty, h)*texture.Stride + clamp(tx, w)*4
+		return texture.Pix[offset : offset+4]
+	}
+
+	t00 := texel(int(x0), int(y0))
+	t10 := texel(int(x0)+1, int(y0))
+	t01 := texel(int(x0), int(y0)+1)
+	t11 := texel(int(x0)+1, int(y0)+1)
+
+	var result [4]uint8
+	for c := 0; c < 4; c++ {
+		top := float64(t00[c])*(1-fx) + float64(t10[c])*fx
+		bottom := float64(t01[c])*(1-fx) + float64(t11[c])*fx
+		result[c] = uint8(math.Round(top*(1-fy) + bottom*fy))
+	}
+	return result
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
+
+func (r *SoftwareRenderer) resize(width, height int) {
+	(*r).Width = width
+	(*r).Height = height
+	(*r).color = image.NewNRGBA(image.Rect(0, 0, width, height))
+	(*r).depth = make([]float32, width*height)
+}
+
+func (r *SoftwareRenderer) image() *image.NRGBA {
+	img := image.NewNRGBA((*r).color.Rect)
+	copy(img.Pix, (*r).color.Pix)
+	return img
+}
+
+func (r *SoftwareRenderer) Delete() {
+	(*r).meshes = nil
+	(*r).textures = make(map[string]*image.RGBA)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestSkinVertexIgnoresMissingNodes(t *testing.T) {
+	tree, _, err := LoadSkeleton("resources/skeletons/cube.sks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, rot, s := tree.getAnimation()
+	m := tree.getJointMatrices()
+	v := mgl32.Vec3{0.5, 1.0, 0.5}
+
+	for _, nodes := range [][2]float32{{2, -1}, {-1, 7}, {5, 9}} {
+		if got := skinVertex(v, nodes, [2]float32{0.5, 0.5}, tr, rot, s, m); got != v.Vec4(1.0) {
+			t.Errorf("nodes %v move the vertex to %v", nodes, got)
+		}
+	}
+
+	// a missing second node leaves the first one in charge
+	want := skinVertex(v, [2]float32{1, -1}, [2]float32{1.0, 0.0}, tr, rot, s, m)
+	if got := skinVertex(v, [2]float32{1, 4}, [2]float32{1.0, 0.0}, tr, rot, s, m); !got.ApproxEqual(want) {
+		t.Errorf("vertex skinned to %v, want %v", got, want)
+	}
+}
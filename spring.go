@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// SpringBone makes a node trail behind its animated position, like an
+// antenna or a tail that keeps wobbling after the body stops
+type SpringBone struct {
+	Node      int
+	Stiffness float32
+	Damping   float32
+	Gravity   mgl32.Vec3
+
+	position    mgl32.Vec3
+	velocity    mgl32.Vec3
+	initialized bool
+}
+
+// simulation step of the spring bones of skeleton files
+const springStep = 1.0 / 120.0
+
+// SpringSystem simulates spring bones with a fixed timestep, so the motion
+// does not depend on the frame rate
+type SpringSystem struct {
+	Bones []*SpringBone
+	// simulation step in seconds
+	Step float64
+	// caps the number of steps per frame after a long pause
+	MaxSteps int
+
+	lastTime    float64
+	accumulator float64
+	started     bool
+}
+
+func NewSpringSystem(step float64) *SpringSystem {
+	return &SpringSystem{make([]*SpringBone, 0), step, 10, 0.0, 0.0, false}
+}
+
+func (s *SpringSystem) addBone(node int, stiffness, damping float32, gravity mgl32.Vec3) {
+	(*s).Bones = append((*s).Bones, &SpringBone{Node: node, Stiffness: stiffness, Damping: damping, Gravity: gravity})
+}
+
+// moves the spring bones to where they are, without advancing the simulation
+func (s *SpringSystem) apply(t AnimationTree) AnimationTree {
+	return s.update(t, (*s).lastTime)
+}
+
+// moves the spring bones of an animated tree to their simulated positions;
+// bones should be added parents first so children follow the moved parents
+func (s *SpringSystem) update(t AnimationTree, currTime float64) AnimationTree {
+	if !(*s).started {
+		(*s).started = true
+		(*s).lastTime = currTime
+	}
+
+	(*s).accumulator += currTime - (*s).lastTime
+	(*s).lastTime = currTime
+
+	// the slack keeps rounding in the frame times from dropping a step
+	steps := 0
+	for ; (*s).accumulator >= (*s).Step-1e-9 && steps < (*s).MaxSteps; steps++ {
+		(*s).accumulator -= (*s).Step
+	}
+	if steps == (*s).MaxSteps {
+		(*s).accumulator = 0.0
+	}
+
+	for _, bone := range (*s).Bones {
+		node := t.Nodes[bone.Node]
+		target := mgl32.Vec3(node.jointPosition())
+
+		if !bone.initialized {
+			bone.initialized = true
+			bone.position = target
+			bone.velocity = mgl32.Vec3{}
+		}
+
+		for i := 0; i < steps; i++ {
+			bone.step(target, float32((*s).Step))
+		}
+
+		node.translate(bone.position.Sub(target))
+	}
+
+	return t
+}
+
+// semi-implicit Euler step of a damped spring pulling towards the target
+func (b *SpringBone) step(target mgl32.Vec3, dt float32) {
+	force := target.Sub((*b).position).Mul((*b).Stiffness).
+		Sub((*b).velocity.Mul((*b).Damping)).
+		Add((*b).Gravity)
+
+	(*b).velocity = (*b).velocity.Add(force.Mul(dt))
+	(*b).position = (*b).position.Add((*b).velocity.Mul(dt))
+}
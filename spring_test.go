@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// a spring on the top node of the cube, sagging under gravity from rest
+func cubeSpring() (AnimationTree, *SpringSystem) {
+	tree := cubeChain()
+	springs := NewSpringSystem(springStep)
+	springs.addBone(1, 150.0, 8.0, mgl32.Vec3{0.0, -5.0, 0.0})
+	return tree, springs
+}
+
+// runs the springs at a frame rate for the given seconds and returns where
+// the spring node ends up
+func runSpring(fps int, seconds float64) mgl32.Vec3 {
+	tree, springs := cubeSpring()
+	frames := int(seconds * float64(fps))
+	for frame := 0; frame <= frames; frame++ {
+		tree.resetTree()
+		tree = springs.update(tree, float64(frame)/float64(fps))
+	}
+	return tree.Nodes[1].worldPosition()
+}
+
+func TestSpringIndependentOfFrameRate(t *testing.T) {
+	for _, seconds := range []float64{0.5, 1.0, 2.0} {
+		slow := runSpring(30, seconds)
+		fast := runSpring(144, seconds)
+		if slow.Sub(fast).Len() > 1e-4 {
+			t.Errorf("after %vs the spring is at %v at 30 fps and %v at 144 fps", seconds, slow, fast)
+		}
+	}
+
+	// the spring did move, so the comparison means something
+	if rest := (mgl32.Vec3{0.0, 1.0, 0.0}); runSpring(30, 0.5).Sub(rest).Len() < 1e-2 {
+		t.Error("the spring does not sag under gravity")
+	}
+}
+
+func TestSpringMaxStepsBoundsCatchUp(t *testing.T) {
+	tree, paused := cubeSpring()
+	paused.update(tree, 0.0)
+	tree.resetTree()
+	tree = paused.update(tree, 5.0)
+	afterPause := tree.Nodes[1].worldPosition()
+
+	// a long frame runs no more steps than MaxSteps in a row would
+	tree, capped := cubeSpring()
+	capped.update(tree, 0.0)
+	tree.resetTree()
+	tree = capped.update(tree, float64(capped.MaxSteps)*capped.Step)
+	if want := tree.Nodes[1].worldPosition(); afterPause.Sub(want).Len() > 1e-6 {
+		t.Errorf("after a 5s frame the spring is at %v, want %v after %d steps", afterPause, want, capped.MaxSteps)
+	}
+
+	// and drops the rest of the pause instead of catching up later
+	tree.resetTree()
+	tree = paused.update(tree, 5.0+paused.Step)
+	next := tree.Nodes[1].worldPosition()
+	tree.resetTree()
+	tree = capped.update(tree, float64(capped.MaxSteps+1)*capped.Step)
+	if want := tree.Nodes[1].worldPosition(); next.Sub(want).Len() > 1e-6 {
+		t.Errorf("the frame after the pause puts the spring at %v, want %v", next, want)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// size of the terminal viewer in characters; every character shows two
+// pixels stacked with the upper half block
+const (
+	terminalColumns = 80
+	terminalRows    = 30
+	terminalFPS     = 30
+)
+
+// RunTerminalViewer plays the clips one after the other with the software
+// renderer until interrupted, drawing every frame to out with 24-bit color
+// escape codes. It needs no display, GPU or GL context, so the viewer also
+// works over ssh and in containers; the binary still links the GL libraries
+// the window viewer is built on.
+func RunTerminalViewer(opts ViewerOptions, out io.Writer, columns, rows int) error {
+	renderer, err := NewSoftwareRenderer(opts.Texture, columns, rows*2)
+	if err != nil {
+		return err
+	}
+	scene, err := NewScene(renderer, columns, rows*2, []string{opts.Mesh})
+	if err != nil {
+		return err
+	}
+	defer scene.Delete()
+
+	tree, constraints, err := LoadSkeleton(opts.Skeleton)
+	if err != nil {
+		return err
+	}
+	if err := scene.checkSkin(tree); err != nil {
+		return err
+	}
+	playback, err := NewPlayback(tree, opts.Clips)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	w := bufio.NewWriter(out)
+	// clear the screen and hide the cursor, showing it again on the way out
+	fmt.Fprint(w, "\x1b[2J\x1b[?25l")
+	defer func() {
+		fmt.Fprint(w, "\x1b[?25h")
+		w.Flush()
+	}()
+
+	ticker := time.NewTicker(time.Second / terminalFPS)
+	defer ticker.Stop()
+	start := time.Now()
+	previousTime := 0.0
+
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+
+		now := time.Since(start).Seconds()
+		elapsed := now - previousTime
+		previousTime = now
+
+		// every clip plays once before the next one starts
+		clipTime := playback.Time
+		playback.advance(elapsed)
+		if playback.Time < clipTime {
+			playback.selectClip((playback.Current + 1) % len(playback.Clips))
+		}
+
+		tree.resetTree()
+		tree = applyConstraints(playback.animate(tree), constraints, now)
+
+		fmt.Fprint(w, "\x1b[H")
+		writeHalfBlocks(w, scene.render(tree))
+		fmt.Fprintf(w, "%s\x1b[K\n", playback.status())
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// writes the image as rows of upper half blocks, the top pixel of every pair
+// as the foreground and the bottom one as the background
+func writeHalfBlocks(w io.Writer, img *image.NRGBA) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top := img.NRGBAAt(x, y)
+			bottom := top
+			if y+1 < bounds.Max.Y {
+				bottom = img.NRGBAAt(x, y+1)
+			}
+			fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		fmt.Fprint(w, "\x1b[0m\n")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestWriteHalfBlocks(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 3))
+	img.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.SetNRGBA(0, 1, color.NRGBA{0, 0, 255, 255})
+	img.SetNRGBA(1, 2, color.NRGBA{0, 255, 0, 255})
+
+	var out bytes.Buffer
+	writeHalfBlocks(&out, img)
+	want := "\x1b[38;2;255;0;0m\x1b[48;2;0;0;255m▀\x1b[38;2;0;0;0m\x1b[48;2;0;0;0m▀\x1b[0m\n" +
+		// the last odd row repeats its pixels below
+		"\x1b[38;2;0;0;0m\x1b[48;2;0;0;0m▀\x1b[38;2;0;255;0m\x1b[48;2;0;255;0m▀\x1b[0m\n"
+	if out.String() != want {
+		t.Errorf("wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunTerminalViewerRejectsMissingSkinNodes(t *testing.T) {
+	opts := ViewerOptions{
+		Mesh:     "resources/models/cube.obj",
+		Skeleton: writeSkeleton(t, "node base -1 0.0 -1.0 0.0"),
+		Texture:  "resources/textures/square.png",
+		Clips:    []string{"resources/animations/jump.saf"},
+	}
+
+	var out bytes.Buffer
+	if err := RunTerminalViewer(opts, &out, 8, 4); err == nil || !strings.Contains(err.Error(), "skinned to node 1") {
+		t.Errorf("cube skinned to a one node skeleton: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// the timeline bar in normalized device coordinates, along the bottom of
+// the window whatever its size
+const (
+	timelineLeft   = -0.95
+	timelineRight  = 0.95
+	timelineBottom = -0.97
+	timelineTop    = -0.87
+)
+
+var (
+	timelineColor    = mgl32.Vec3{0.25, 0.25, 0.25}
+	playheadColor    = mgl32.Vec3{1.0, 0.2, 0.2}
+	currentKeyColor  = mgl32.Vec3{1.0, 1.0, 1.0}
+	timelineKeyColor = jointColor
+)
+
+// Timeline draws the keyframes of the playing clip as ticks on a bar with a
+// playhead at the current time, and scrubs the time while the bar is dragged
+type Timeline struct {
+	Enabled bool
+
+	program uint32
+	vao     uint32
+	vbo     uint32
+
+	projectionUniform int32
+	cameraUniform     int32
+	modelUniform      int32
+	pointSizeUniform  int32
+
+	scrubbing bool
+}
+
+// NewTimeline takes the shaders of the skeleton overlay, which draw colored
+// lines and triangles
+func NewTimeline(vertexShader, fragmentShader string) (*Timeline, error) {
+	program, err := LoadShaderProgram(vertexShader, fragmentShader)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Timeline{program: program}
+	t.projectionUniform = gl.GetUniformLocation(program, gl.Str("projection\x00"))
+	t.cameraUniform = gl.GetUniformLocation(program, gl.Str("camera\x00"))
+	t.modelUniform = gl.GetUniformLocation(program, gl.Str("model\x00"))
+	t.pointSizeUniform = gl.GetUniformLocation(program, gl.Str("pointSize\x00"))
+	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+
+	gl.GenVertexArrays(1, &t.vao)
+	gl.BindVertexArray(t.vao)
+	gl.GenBuffers(1, &t.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, t.vbo)
+	err = colorLayout.bind(program)
+	gl.BindVertexArray(0)
+	if err != nil {
+		t.Delete()
+		return nil, err
+	}
+	return t, nil
+}
+
+// x coordinate of a time of the clip on the bar
+func timelineX(time, duration float64) float32 {
+	if duration <= 0 {
+		return timelineLeft
+	}
+	return timelineLeft + float32(time/duration)*(timelineRight-timelineLeft)
+}
+
+// the clip time under a cursor position, in window coordinates, and whether
+// the cursor is over the bar
+func timelineTime(window *glfw.Window, x, y, duration float64) (float64, bool) {
+	width, height := window.GetSize()
+	if width == 0 || height == 0 {
+		return 0, false
+	}
+	ndcX := 2*x/float64(width) - 1
+	ndcY := 1 - 2*y/float64(height)
+
+	over := ndcX >= timelineLeft && ndcX <= timelineRight && ndcY >= timelineBottom && ndcY <= timelineTop
+	fraction := mgl32.Clamp(float32((ndcX-timelineLeft)/(timelineRight-timelineLeft)), 0, 1)
+	return float64(fraction) * duration, over
+}
+
+// draws the bar over whatever is in the bound framebuffer
+func (t *Timeline) draw(playback *Playback, currentKey int) {
+	if !(*t).Enabled {
+		return
+	}
+
+	clip := playback.clip()
+	duration := float64(clip.duration())
+
+	data := make([]float32, 0)
+	corners := []mgl32.Vec3{
+		{timelineLeft, timelineBottom, 0}, {timelineRight, timelineBottom, 0}, {timelineRight, timelineTop, 0},
+		{timelineLeft, timelineBottom, 0}, {timelineRight, timelineTop, 0}, {timelineLeft, timelineTop, 0},
+	}
+	for _, corner := range corners {
+		data = appendVertex(data, corner, timelineColor)
+	}
+
+	lines := 0
+	for i := range clip.TimeStamps {
+		color := timelineKeyColor
+		if i == currentKey {
+			color = currentKeyColor
+		}
+		x := timelineX(playback.keyframeTime(i), duration)
+		data = appendVertex(data, mgl32.Vec3{x, timelineBottom, 0}, color)
+		data = appendVertex(data, mgl32.Vec3{x, timelineTop, 0}, color)
+		lines++
+	}
+
+	x := timelineX(playback.Time, duration)
+	data = appendVertex(data, mgl32.Vec3{x, timelineBottom - 0.01, 0}, playheadColor)
+	data = appendVertex(data, mgl32.Vec3{x, timelineTop + 0.01, 0}, playheadColor)
+	lines++
+
+	identity := mgl32.Ident4()
+	gl.UseProgram((*t).program)
+	gl.UniformMatrix4fv((*t).projectionUniform, 1, false, &identity[0])
+	gl.UniformMatrix4fv((*t).cameraUniform, 1, false, &identity[0])
+	gl.UniformMatrix4fv((*t).modelUniform, 1, false, &identity[0])
+	gl.Uniform1f((*t).pointSizeUniform, 1.0)
+
+	gl.BindVertexArray((*t).vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, (*t).vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, gl.Ptr(data), gl.STREAM_DRAW)
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(corners)))
+	gl.DrawArrays(gl.LINES, int32(len(corners)), int32(lines*2))
+	gl.Enable(gl.DEPTH_TEST)
+	gl.BindVertexArray(0)
+}
+
+// scrubs the playback while the left button drags on the bar; other mouse
+// input goes on to the callbacks set before, such as the camera's
+func (t *Timeline) bindInput(window *glfw.Window, playback *Playback) {
+	var previousButton glfw.MouseButtonCallback
+	var previousCursor glfw.CursorPosCallback
+
+	scrub := func(w *glfw.Window, x, y float64) bool {
+		time, over := timelineTime(w, x, y, float64(playback.clip().duration()))
+		if over || (*t).scrubbing {
+			playback.Paused = true
+			playback.Time = time
+		}
+		return over
+	}
+
+	previousButton = window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		if (*t).Enabled && button == glfw.MouseButtonLeft {
+			if action == glfw.Release && (*t).scrubbing {
+				(*t).scrubbing = false
+				return
+			}
+			if action == glfw.Press {
+				x, y := w.GetCursorPos()
+				if scrub(w, x, y) {
+					(*t).scrubbing = true
+					return
+				}
+			}
+		}
+		if previousButton != nil {
+			previousButton(w, button, action, mods)
+		}
+	})
+
+	previousCursor = window.SetCursorPosCallback(func(w *glfw.Window, x, y float64) {
+		if (*t).scrubbing {
+			scrub(w, x, y)
+			return
+		}
+		if previousCursor != nil {
+			previousCursor(w, x, y)
+		}
+	})
+}
+
+func (t *Timeline) Delete() {
+	gl.DeleteBuffers(1, &(*t).vbo)
+	gl.DeleteVertexArrays(1, &(*t).vao)
+	gl.DeleteProgram((*t).program)
+}
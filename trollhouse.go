@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"image"
 	"image/draw"
@@ -14,182 +15,34 @@ import (
 	"strings"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
-	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
 const windowWidth = 800
 const windowHeight = 600
 
+// must match the uniform array sizes in shaders/test.vs
+const maxAnimationNodes = 16
+
 func init() {
 	// GLFW event handling must run on the main OS thread
 	runtime.LockOSThread()
 }
 
 func main() {
-	if err := glfw.Init(); err != nil {
-		log.Fatalln("failed to initialize glfw:", err)
-	}
-	defer glfw.Terminate()
-
-	glfw.WindowHint(glfw.Resizable, glfw.False)
-	glfw.WindowHint(glfw.ContextVersionMajor, 4)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
-	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
-	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
-	window, err := glfw.CreateWindow(windowWidth, windowHeight, "Cube", nil, nil)
-	if err != nil {
-		panic(err)
-	}
-	window.MakeContextCurrent()
-
-	// Initialize Glow
-	if err := gl.Init(); err != nil {
-		panic(err)
-	}
-
-	version := gl.GoStr(gl.GetString(gl.VERSION))
-	fmt.Println("OpenGL version", version)
-
-	// Configure the vertex and fragment shaders
-	program, err := LoadShaderProgram("./shaders/test.vs", "./shaders/test.fs")
-	if err != nil {
-		panic(err)
-	}
-
-	gl.UseProgram(program)
-
-	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, 0.1, 10.0)
-	projectionUniform := gl.GetUniformLocation(program, gl.Str("projection\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
-
-	camera := mgl32.LookAtV(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
-	cameraUniform := gl.GetUniformLocation(program, gl.Str("camera\x00"))
-	gl.UniformMatrix4fv(cameraUniform, 1, false, &camera[0])
-
-	model := mgl32.Ident4()
-	modelUniform := gl.GetUniformLocation(program, gl.Str("model\x00"))
-	gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
-
-	textureUniform := gl.GetUniformLocation(program, gl.Str("tex\x00"))
-	gl.Uniform1i(textureUniform, 0)
-
-	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
-
-	// Load the texture
-	texture, err := LoadTexture("./resources/textures/square.png")
-	if err != nil {
+	logSpec := flag.String("log", os.Getenv(logEnv),
+		"log levels, e.g. debug or warn,animation=debug; categories are animation, render and assets")
+	flag.Parse()
+	if err := configureLogging(*logSpec); err != nil {
 		log.Fatalln(err)
 	}
 
-	// Configure the vertex data
-	var vao uint32
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
-
-	var vbo uint32
-	gl.GenBuffers(1, &vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-
-	gl.BufferData(gl.ARRAY_BUFFER, len(cubeVertices)*4, gl.Ptr(cubeVertices), gl.STATIC_DRAW)
-
-	vertAttrib := uint32(gl.GetAttribLocation(program, gl.Str("vert\x00")))
-	gl.EnableVertexAttribArray(vertAttrib)
-	gl.VertexAttribPointer(vertAttrib, 3, gl.FLOAT, false, 7*4, gl.PtrOffset(0))
-
-	texCoordAttrib := uint32(gl.GetAttribLocation(program, gl.Str("vertTexCoord\x00")))
-	gl.EnableVertexAttribArray(texCoordAttrib)
-	gl.VertexAttribPointer(texCoordAttrib, 2, gl.FLOAT, false, 7*4, gl.PtrOffset(3*4))
-
-	skinAttrib := uint32(gl.GetAttribLocation(program, gl.Str("skinAttr\x00")))
-	gl.EnableVertexAttribArray(skinAttrib)
-	gl.VertexAttribPointer(skinAttrib, 2, gl.FLOAT, false, 7*4, gl.PtrOffset(5*4))
-
-	// Create animation tree
-	node := NewAnimationNode([3]float32{0.0, -1.0, 0.0})
-	node.addChild([3]float32{0.0, 1.0, 0.0})
-
-	tree := AnimationTree{make([]*AnimationNode, 0), make([]SkinVertex, 0)}
-	tree.addNodes(&node)
-
-	// for i := 0; i < 6; i++ {
-	// 	sv := SkinVertex{i, make(map[int]float32)}
-	// 	sv.Weights[0] = 1.0
-	// 	tree.Skin = append(tree.Skin, sv)
-	// }
-
-	// for i := 6; i < 12; i++ {
-	// 	sv := SkinVertex{i, make(map[int]float32)}
-	// 	sv.Weights[1] = 1.0
-	// 	tree.Skin = append(tree.Skin, sv)
-	// }
-
-	anim1 := LoadAnimation("./resources/animations/twist.saf")
-	anim2 := LoadAnimation("./resources/animations/bounce.saf")
-
-	animationUniformT := gl.GetUniformLocation(program, gl.Str("animT\x00"))
-	animationUniformR := gl.GetUniformLocation(program, gl.Str("animR\x00"))
-	animationUniformS := gl.GetUniformLocation(program, gl.Str("animS\x00"))
-
-	// Configure global settings
-	gl.Enable(gl.DEPTH_TEST)
-	gl.DepthFunc(gl.LESS)
-	gl.ClearColor(0.1, 0.1, 0.1, 1.0)
-
-	// angle := 0.0
-	previousTime := glfw.GetTime()
-
-	anim1.begin(previousTime)
-	anim2.begin(previousTime)
-	for !window.ShouldClose() {
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-
-		// Update
-		time := glfw.GetTime()
-		// elapsed := time - previousTime
-		previousTime = time
-
-		// angle += elapsed
-		// model = mgl32.HomogRotate3D(float32(angle), mgl32.Vec3{0, 1, 0})
-
-		// node.addTranslation([3]float32{0.0, 0.25 * float32(elapsed), 0.0})
-
-		// gl.PolygonMode(GL_FRONT_AND_BACK, GL_LINE)
-
-		// Render
-		gl.UseProgram(program)
-		gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
-
-		tree.resetTree()
-		t, r, s := anim2.animate(anim1.animate(tree, time), time).getAnimation()
-
-		fmt.Println("===")
-		fmt.Println(t)
-		fmt.Println(r)
-		fmt.Println(s)
-		fmt.Println()
-
-		gl.Uniform3fv(animationUniformT,
-			2,
-			&(t[0]))
-		gl.Uniform1fv(animationUniformR,
-			2,
-			&(r[0]))
-		gl.Uniform3fv(animationUniformS,
-			2,
-			&(s[0]))
-
-		gl.BindVertexArray(vao)
-
-		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, texture)
-
-		gl.DrawArrays(gl.TRIANGLES, 0, 10*2*3)
-
-		// Maintenance
-		window.SwapBuffers()
-		glfw.PollEvents()
+	// without a command the viewer opens with the default assets
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"view"}
 	}
+	runCommand(args)
 }
 
 func compileShader(source string, shaderType uint32) (uint32, error) {
@@ -270,21 +123,32 @@ func LoadShaderProgram(vertexShaderFilename, fragmentShaderFilename string) (uin
 	return program, nil
 }
 
-func LoadTexture(file string) (uint32, error) {
+// decodes an image file into the pixel layout textures are uploaded in
+func loadTextureImage(file string) (*image.RGBA, error) {
 	imgFile, err := os.Open(file)
 	if err != nil {
-		return 0, fmt.Errorf("texture %q not found on disk: %v", file, err)
+		return nil, fmt.Errorf("texture %q not found on disk: %v", file, err)
 	}
+	defer imgFile.Close()
+
 	img, _, err := image.Decode(imgFile)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	rgba := image.NewRGBA(img.Bounds())
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
 	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, fmt.Errorf("unsupported stride")
+		return nil, fmt.Errorf("unsupported stride")
+	}
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, nil
+}
+
+func LoadTexture(file string) (uint32, error) {
+	rgba, err := loadTextureImage(file)
+	if err != nil {
+		return 0, err
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
 	var texture uint32
 	gl.GenTextures(1, &texture)
@@ -308,102 +172,24 @@ func LoadTexture(file string) (uint32, error) {
 	return texture, nil
 }
 
-var cubeVertices = []float32{
-	//  X, Y, Z, U, V
-	// Bottom
-	-1.0, -1.0, -1.0, 0.0, 0.0, 0.0, -1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0, 0.0, -1.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0, 0.0, -1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0, 0.0, -1.0,
-	1.0, -1.0, 1.0, 1.0, 1.0, 0.0, -1.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0, 0.0, -1.0,
-
-	// Top
-	-1.0, 1.0, -1.0, 0.0, 0.0, 1.0, -1.0,
-	-1.0, 1.0, 1.0, 0.0, 1.0, 1.0, -1.0,
-	1.0, 1.0, -1.0, 1.0, 0.0, 1.0, -1.0,
-	1.0, 1.0, -1.0, 1.0, 0.0, 1.0, -1.0,
-	-1.0, 1.0, 1.0, 0.0, 1.0, 1.0, -1.0,
-	1.0, 1.0, 1.0, 1.0, 1.0, 1.0, -1.0,
-
-	// Upper Front
-	-1.0, 0.0, 1.0, 1.0, 0.0, 0.0, 1.0,
-	1.0, 0.0, 1.0, 0.0, 0.0, 0.0, 1.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0, 1.0, -1.0,
-	1.0, 0.0, 1.0, 0.0, 0.0, 0.0, 1.0,
-	1.0, 1.0, 1.0, 0.0, 1.0, 1.0, -1.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0, 1.0, -1.0,
-
-	// Lower Front
-	-1.0, -1.0, 1.0, 1.0, 0.0, 0.0, -1.0,
-	1.0, -1.0, 1.0, 0.0, 0.0, 0.0, -1.0,
-	-1.0, 0.0, 1.0, 1.0, 1.0, 1.0, 0.0,
-	1.0, -1.0, 1.0, 0.0, 0.0, 0.0, -1.0,
-	1.0, 0.0, 1.0, 0.0, 1.0, 1.0, 0.0,
-	-1.0, 0.0, 1.0, 1.0, 1.0, 1.0, 0.0,
-
-	// Upper Back
-	-1.0, 0.0, -1.0, 0.0, 0.0, 0.0, 1.0,
-	-1.0, 1.0, -1.0, 0.0, 1.0, 1.0, -1.0,
-	1.0, 0.0, -1.0, 1.0, 0.0, 0.0, 1.0,
-	1.0, 0.0, -1.0, 1.0, 0.0, 0.0, 1.0,
-	-1.0, 1.0, -1.0, 0.0, 1.0, 1.0, -1.0,
-	1.0, 1.0, -1.0, 1.0, 1.0, 1.0, -1.0,
-
-	// Lower Back
-	-1.0, -1.0, -1.0, 0.0, 0.0, 0.0, -1.0,
-	-1.0, 0.0, -1.0, 0.0, 1.0, 1.0, 0.0,
-	1.0, -1.0, -1.0, 1.0, 0.0, 0.0, -1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0, 0.0, -1.0,
-	-1.0, 0.0, -1.0, 0.0, 1.0, 1.0, 0.0,
-	1.0, 0.0, -1.0, 1.0, 1.0, 1.0, 0.0,
-
-	// Upper Left
-	-1.0, 0.0, 1.0, 0.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, -1.0, 1.0, 0.0, 1.0, -1.0,
-	-1.0, 0.0, -1.0, 0.0, 0.0, 0.0, 1.0,
-	-1.0, 0.0, 1.0, 0.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0, 1.0, -1.0,
-	-1.0, 1.0, -1.0, 1.0, 0.0, 1.0, -1.0,
-
-	// Lower Left
-	-1.0, -1.0, 1.0, 0.0, 1.0, 0.0, -1.0,
-	-1.0, 0.0, -1.0, 1.0, 0.0, 1.0, 0.0,
-	-1.0, -1.0, -1.0, 0.0, 0.0, 0.0, -1.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0, 0.0, -1.0,
-	-1.0, 0.0, 1.0, 1.0, 1.0, 1.0, 0.0,
-	-1.0, 0.0, -1.0, 1.0, 0.0, 1.0, 0.0,
-
-	// Upper Right
-	1.0, 0.0, 1.0, 1.0, 1.0, 0.0, 1.0,
-	1.0, 0.0, -1.0, 1.0, 0.0, 0.0, 1.0,
-	1.0, 1.0, -1.0, 0.0, 0.0, 1.0, -1.0,
-	1.0, 0.0, 1.0, 1.0, 1.0, 0.0, 1.0,
-	1.0, 1.0, -1.0, 0.0, 0.0, 1.0, -1.0,
-	1.0, 1.0, 1.0, 0.0, 1.0, 1.0, -1.0,
-
-	// Lower Right
-	1.0, -1.0, 1.0, 1.0, 1.0, 0.0, -1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0, 0.0, -1.0,
-	1.0, 0.0, -1.0, 0.0, 0.0, 1.0, 0.0,
-	1.0, -1.0, 1.0, 1.0, 1.0, 0.0, -1.0,
-	1.0, 0.0, -1.0, 0.0, 0.0, 1.0, 0.0,
-	1.0, 0.0, 1.0, 0.0, 1.0, 1.0, 0.0,
-}
-
 type AnimationNode struct {
+	Name        string
 	Pos         [3]float32
 	Translation [3]float32
 	RotationY   float32
-	Scale       [3]float32
-	Children    []*AnimationNode
+	// rotation around the node's own joint, applied before the translation
+	Rotation mgl32.Quat
+	Scale    [3]float32
+	Children []*AnimationNode
 }
 
 func NewAnimationNode(pos [3]float32) AnimationNode {
 	return AnimationNode{
+		"",
 		pos,
 		[3]float32{0.0, 0.0, 0.0},
 		0.0,
+		mgl32.QuatIdent(),
 		[3]float32{1.0, 1.0, 1.0},
 		make([]*AnimationNode, 0)}
 }
@@ -444,6 +230,26 @@ func (p *AnimationNode) resetRotationY() {
 	}
 }
 
+// rotates the node and all its children around the pivot point, given in
+// the same space as the joint position
+func (p *AnimationNode) rotate(rot mgl32.Quat, pivot [3]float32) {
+	joint := mgl32.Vec3(p.jointPosition())
+	moved := mgl32.Vec3(pivot).Add(rot.Rotate(joint.Sub(pivot)))
+	(*p).Translation = mgl32.Vec3((*p).Translation).Add(moved.Sub(joint))
+	(*p).Rotation = rot.Mul((*p).Rotation).Normalize()
+
+	for _, child := range (*p).Children {
+		child.rotate(rot, pivot)
+	}
+}
+
+func (p *AnimationNode) resetRotation() {
+	(*p).Rotation = mgl32.QuatIdent()
+	for _, child := range (*p).Children {
+		child.resetRotation()
+	}
+}
+
 func (p *AnimationNode) scale(pos [3]float32) {
 	(*p).Scale[0] *= pos[0]
 	(*p).Scale[1] *= pos[1]
@@ -461,6 +267,30 @@ func (p *AnimationNode) resetScale() {
 	}
 }
 
+// position of the joint after translation, before the scale and Y rotation
+// that the shader applies around the origin
+func (p AnimationNode) jointPosition() [3]float32 {
+	return mgl32.Vec3(p.Pos).Add(p.Translation)
+}
+
+// maps a point from joint space to world space, the same way the shader does
+func (p AnimationNode) toWorld(v mgl32.Vec3) mgl32.Vec3 {
+	scaled := mgl32.Vec3{v[0] * p.Scale[0], v[1] * p.Scale[1], v[2] * p.Scale[2]}
+	return mgl32.Rotate3DY(-p.RotationY).Mul3x1(scaled)
+}
+
+// maps a point from world space back to joint space
+func (p AnimationNode) toJointSpace(v mgl32.Vec3) mgl32.Vec3 {
+	unrotated := mgl32.Rotate3DY(p.RotationY).Mul3x1(v)
+	return mgl32.Vec3{safeDiv(unrotated[0], p.Scale[0]),
+		safeDiv(unrotated[1], p.Scale[1]),
+		safeDiv(unrotated[2], p.Scale[2])}
+}
+
+func (p AnimationNode) worldPosition() mgl32.Vec3 {
+	return p.toWorld(p.jointPosition())
+}
+
 type SkinVertex struct {
 	VertexIdx int
 	Weights   map[int]float32
@@ -495,19 +325,98 @@ func (at AnimationTree) getAnimation() ([]float32, []float32, []float32) {
 	return t, r, s
 }
 
+// returns, for every node, the matrix rotating a vertex around the node's joint
+func (at AnimationTree) getJointMatrices() []float32 {
+	m := make([]float32, len(at.Nodes)*16)
+
+	for i, node := range at.Nodes {
+		pos := mgl32.Vec3(node.Pos)
+		joint := mgl32.Translate3D(pos[0], pos[1], pos[2]).
+			Mul4(node.Rotation.Mat4()).
+			Mul4(mgl32.Translate3D(-pos[0], -pos[1], -pos[2]))
+		copy(m[i*16:], joint[:])
+	}
+	return m
+}
+
+// returns, for every node, the full transform the shader applies to the
+// vertices skinned to it
+func (at AnimationTree) skinMatrices() []mgl32.Mat4 {
+	m := make([]mgl32.Mat4, len(at.Nodes))
+	joints := at.getJointMatrices()
+
+	for i, node := range at.Nodes {
+		var joint mgl32.Mat4
+		copy(joint[:], joints[i*16:])
+		m[i] = mgl32.HomogRotate3DY(-node.RotationY).
+			Mul4(mgl32.Scale3D(node.Scale[0], node.Scale[1], node.Scale[2])).
+			Mul4(mgl32.Translate3D(node.Translation[0], node.Translation[1], node.Translation[2])).
+			Mul4(joint)
+	}
+	return m
+}
+
 func (t *AnimationTree) resetTree() {
 	for _, n := range (*t).Nodes {
 		n.resetTranslation()
 		n.resetRotationY()
+		n.resetRotation()
 		n.resetScale()
 	}
 }
 
+// returns the index of the node's parent or -1 for a root node
+func (t AnimationTree) parentOf(idx int) int {
+	for i, n := range t.Nodes {
+		for _, child := range n.Children {
+			if child == t.Nodes[idx] {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// returns the index of the node with the given name or -1 if there is none
+func (t AnimationTree) findNode(name string) int {
+	for i, n := range t.Nodes {
+		if n.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// reports whether every node is a direct child of the previous one
+func (t AnimationTree) isChain(indices ...int) bool {
+	for i, idx := range indices {
+		if idx < 0 || idx >= len(t.Nodes) {
+			return false
+		}
+		if i == 0 {
+			continue
+		}
+
+		found := false
+		for _, child := range t.Nodes[indices[i-1]].Children {
+			if child == t.Nodes[idx] {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 type NodeAnimationTranslation struct {
 	NodeIdx     int
 	Translation [3]float32
 	RotationY   float32
 	Scale       [3]float32
+	// rotation around the node's joint, relative to its parent
+	Rotation mgl32.Quat
 }
 
 type AnimationTimeStamp struct {
@@ -521,11 +430,22 @@ type Animation struct {
 	TimeStamps        []AnimationTimeStamp
 }
 
+// LoadAnimation reads a .saf clip and exits when it cannot
 func LoadAnimation(filename string) Animation {
-	file, err := os.Open(filename)
+	anim, err := loadAnimation(filename)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return anim
+}
+
+// reads a .saf clip: an optional "duration <seconds>" header, then "ts
+// <time point>" lines each followed by the keys of that time point
+func loadAnimation(filename string) (Animation, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Animation{}, fmt.Errorf("clip %q not found on disk: %v", filename, err)
+	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
@@ -533,58 +453,115 @@ func LoadAnimation(filename string) Animation {
 	anim.StartTime = 0.0
 	anim.TimeStampDuration = 1.0
 	anim.TimeStamps = make([]AnimationTimeStamp, 0)
-	var timestamp AnimationTimeStamp
-	first := true
-	for scanner.Scan() {
-		words := strings.Split(scanner.Text(), " ")
-		if words[0] == "ts" {
-			if first {
-				first = false
-			} else {
-				anim.TimeStamps = append(anim.TimeStamps, timestamp)
-			}
-			timestamp = AnimationTimeStamp{}
+	for line := 1; scanner.Scan(); line++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
 
-			ts, _ := strconv.Atoi(words[1])
-			timestamp.TimePoint = ts
-			timestamp.Translations = make([]NodeAnimationTranslation, 0)
+		if words[0] == "duration" {
+			// optional header with the length of one time point in seconds
+			if len(words) != 2 {
+				return Animation{}, fmt.Errorf("%s:%d: duration needs one value", filename, line)
+			}
+			aux, err := strconv.ParseFloat(words[1], 32)
+			if err != nil || aux <= 0 {
+				return Animation{}, fmt.Errorf("%s:%d: bad duration %q", filename, line, words[1])
+			}
+			anim.TimeStampDuration = float32(aux)
+		} else if words[0] == "ts" {
+			if len(words) != 2 {
+				return Animation{}, fmt.Errorf("%s:%d: ts needs a time point", filename, line)
+			}
+			ts, err := strconv.Atoi(words[1])
+			if err != nil {
+				return Animation{}, fmt.Errorf("%s:%d: bad time point %q", filename, line, words[1])
+			}
+			anim.TimeStamps = append(anim.TimeStamps, AnimationTimeStamp{ts, make([]NodeAnimationTranslation, 0)})
 		} else {
-			var translation NodeAnimationTranslation
-			translation.NodeIdx, _ = strconv.Atoi(words[0])
+			if len(anim.TimeStamps) == 0 {
+				return Animation{}, fmt.Errorf("%s:%d: key before the first ts", filename, line)
+			}
+			if len(words) != 8 && len(words) != 12 {
+				return Animation{}, fmt.Errorf("%s:%d: keys have 8 values, or 12 with a joint rotation", filename, line)
+			}
 
-			var aux float64
+			var translation NodeAnimationTranslation
+			translation.NodeIdx, err = strconv.Atoi(words[0])
+			if err != nil || translation.NodeIdx < 0 {
+				return Animation{}, fmt.Errorf("%s:%d: bad node index %q", filename, line, words[0])
+			}
+			values, err := parseFloats(words[1:])
+			if err != nil {
+				return Animation{}, fmt.Errorf("%s:%d: %v", filename, line, err)
+			}
 
-			aux, _ = strconv.ParseFloat(words[1], 32)
-			translation.Translation[0] = float32(aux)
+			translation.Translation = [3]float32{values[0], values[1], values[2]}
+			translation.RotationY = values[3]
+			translation.Scale = [3]float32{values[4], values[5], values[6]}
+			translation.Rotation = mgl32.QuatIdent()
 
-			aux, _ = strconv.ParseFloat(words[2], 32)
-			translation.Translation[1] = float32(aux)
+			// optional joint rotation as a quaternion x y z w
+			if len(values) == 11 {
+				translation.Rotation = mgl32.Quat{W: values[10], V: mgl32.Vec3{values[7], values[8], values[9]}}.Normalize()
+			}
 
-			aux, _ = strconv.ParseFloat(words[3], 32)
-			translation.Translation[2] = float32(aux)
+			last := &anim.TimeStamps[len(anim.TimeStamps)-1]
+			last.Translations = append(last.Translations, translation)
+		}
+	}
 
-			aux, _ = strconv.ParseFloat(words[4], 32)
-			translation.RotationY = float32(aux)
+	if err := scanner.Err(); err != nil {
+		return Animation{}, err
+	}
+	if len(anim.TimeStamps) == 0 {
+		return Animation{}, fmt.Errorf("%s: no time stamps", filename)
+	}
 
-			aux, _ = strconv.ParseFloat(words[5], 32)
-			translation.Scale[0] = float32(aux)
+	return anim, nil
+}
 
-			aux, _ = strconv.ParseFloat(words[6], 32)
-			translation.Scale[1] = float32(aux)
+// returns an error when the animation keys a node the tree does not have
+func (a Animation) checkNodes(t AnimationTree) error {
+	for _, ts := range a.TimeStamps {
+		for _, key := range ts.Translations {
+			if key.NodeIdx < 0 || key.NodeIdx >= len(t.Nodes) {
+				return fmt.Errorf("animates node %d, the skeleton has %d", key.NodeIdx, len(t.Nodes))
+			}
+		}
+	}
+	return nil
+}
 
-			aux, _ = strconv.ParseFloat(words[7], 32)
-			translation.Scale[2] = float32(aux)
+func SaveAnimation(filename string, anim Animation) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-			timestamp.Translations = append(timestamp.Translations, translation)
+	w := bufio.NewWriter(file)
+	if anim.TimeStampDuration != 1.0 {
+		fmt.Fprintf(w, "duration %s\n", formatFloats(anim.TimeStampDuration))
+	}
+	for _, ts := range anim.TimeStamps {
+		fmt.Fprintf(w, "ts %d\n", ts.TimePoint)
+		for _, trans := range ts.Translations {
+			fmt.Fprintf(w, "%d %s %s %s", trans.NodeIdx,
+				formatFloats(trans.Translation[:]...),
+				formatFloats(trans.RotationY),
+				formatFloats(trans.Scale[:]...))
+			if !trans.Rotation.ApproxEqual(mgl32.QuatIdent()) {
+				fmt.Fprintf(w, " %s %s", formatFloats(trans.Rotation.V[:]...), formatFloats(trans.Rotation.W))
+			}
+			fmt.Fprintln(w)
 		}
 	}
-	anim.TimeStamps = append(anim.TimeStamps, timestamp)
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+	if err := w.Flush(); err != nil {
+		return err
 	}
-
-	return anim
+	return file.Close()
 }
 
 func (a *Animation) begin(startTime float64) {
@@ -594,10 +571,28 @@ func (a *Animation) begin(startTime float64) {
 func (a Animation) animate(t AnimationTree, currTime float64) AnimationTree {
 	// bound animation time
 	time := float32(currTime - a.StartTime)
-	for finalTimePoint := float32(a.TimeStamps[len(a.TimeStamps)-1].TimePoint) * a.TimeStampDuration; time > finalTimePoint; {
+	for finalTimePoint := a.duration(); time > finalTimePoint; {
 		time -= finalTimePoint
 	}
 
+	for _, key := range a.sample(time) {
+		node := t.Nodes[key.NodeIdx]
+		node.translate(key.Translation)
+		// keys are relative to the parent, which was already rotated
+		node.rotate(node.Rotation.Mul(key.Rotation).Mul(node.Rotation.Inverse()), node.jointPosition())
+		node.rotateY(key.RotationY)
+		node.scale(key.Scale)
+	}
+
+	return t
+}
+
+func (a Animation) duration() float32 {
+	return float32(a.TimeStamps[len(a.TimeStamps)-1].TimePoint) * a.TimeStampDuration
+}
+
+// returns the interpolated node keys at a time between 0 and the duration
+func (a Animation) sample(time float32) []NodeAnimationTranslation {
 	// get current run context
 	var pos int
 	for i, ts := range a.TimeStamps {
@@ -617,27 +612,29 @@ func (a Animation) animate(t AnimationTree, currTime float64) AnimationTree {
 	factor := (time - prevTimePoint) / (float32(ts.TimePoint)*a.TimeStampDuration - prevTimePoint)
 	// fmt.Printf("currTimePoint = %f, prevTimePoint = %f, time = %f, factor = %f\n", float32(ts.TimePoint)*a.TimeStampDuration, prevTimePoint, time, factor)
 
+	keys := make([]NodeAnimationTranslation, len(ts.Translations))
 	for i, trans := range ts.Translations {
 		currTrans := [3]float32{0.0, 0.0, 0.0}
 		var currRotationY float32
 		currScale := [3]float32{1.0, 1.0, 1.0}
+		currRotation := mgl32.QuatIdent()
 
 		if pos > 0 {
 			currTrans = vec3Lerp(a.TimeStamps[pos-1].Translations[i].Translation, trans.Translation, factor)
 			currRotationY = lerp(a.TimeStamps[pos-1].Translations[i].RotationY, trans.RotationY, factor)
 			currScale = vec3Lerp(a.TimeStamps[pos-1].Translations[i].Scale, trans.Scale, factor)
+			currRotation = mgl32.QuatSlerp(a.TimeStamps[pos-1].Translations[i].Rotation, trans.Rotation, factor)
 		} else {
 			currTrans = vec3Lerp(currTrans, trans.Translation, factor)
 			currRotationY = lerp(currRotationY, trans.RotationY, factor)
 			currScale = vec3Lerp(currScale, trans.Scale, factor)
+			currRotation = mgl32.QuatSlerp(currRotation, trans.Rotation, factor)
 		}
 
-		t.Nodes[trans.NodeIdx].translate(currTrans)
-		t.Nodes[trans.NodeIdx].rotateY(currRotationY)
-		t.Nodes[trans.NodeIdx].scale(currScale)
+		keys[i] = NodeAnimationTranslation{trans.NodeIdx, currTrans, currRotationY, currScale, currRotation}
 	}
 
-	return t
+	return keys
 }
 
 // factor should be between 0 and 1
@@ -645,6 +642,14 @@ func lerp(a, b, factor float32) float32 {
 	return a*(float32(1)-factor) + b*factor
 }
 
+// returns 0 instead of dividing by a zero scale
+func safeDiv(a, b float32) float32 {
+	if b == 0.0 {
+		return 0.0
+	}
+	return a / b
+}
+
 func vec3Lerp(a, b [3]float32, factor float32) [3]float32 {
 	return [3]float32{lerp(a[0], b[0], factor),
 		lerp(a[1], b[1], factor),
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// VertexAttribute is one interleaved attribute of a vertex buffer, named
+// like the shader input it feeds
+type VertexAttribute struct {
+	Name       string
+	Components int32
+	// gl.FLOAT, gl.INT, gl.UNSIGNED_BYTE and the like
+	Type       uint32
+	Normalized bool
+	// optional attributes may be missing from the program, for example when
+	// the shader compiler drops an unused input
+	Optional bool
+}
+
+// VertexLayout describes the attributes of a vertex buffer in the order they
+// are interleaved; offsets and the stride follow from their sizes
+type VertexLayout struct {
+	Attributes []VertexAttribute
+}
+
+func NewVertexLayout(attributes ...VertexAttribute) VertexLayout {
+	return VertexLayout{attributes}
+}
+
+// the layout of Mesh.vertexData
+var meshLayout = NewVertexLayout(
+	VertexAttribute{Name: "vert", Components: 3, Type: gl.FLOAT},
+	VertexAttribute{Name: "vertTexCoord", Components: 2, Type: gl.FLOAT},
+	VertexAttribute{Name: "vertNormal", Components: 3, Type: gl.FLOAT, Optional: true},
+	VertexAttribute{Name: "skinAttr", Components: 2, Type: gl.FLOAT},
+	VertexAttribute{Name: "skinWeights", Components: 2, Type: gl.FLOAT},
+)
+
+func componentSizeGL(componentType uint32) int {
+	switch componentType {
+	case gl.BYTE, gl.UNSIGNED_BYTE:
+		return 1
+	case gl.SHORT, gl.UNSIGNED_SHORT, gl.HALF_FLOAT:
+		return 2
+	case gl.INT, gl.UNSIGNED_INT, gl.FLOAT:
+		return 4
+	case gl.DOUBLE:
+		return 8
+	}
+	return 0
+}
+
+func (a VertexAttribute) size() int {
+	return int(a.Components) * componentSizeGL(a.Type)
+}
+
+// returns the size of a whole vertex in bytes
+func (l VertexLayout) Stride() int {
+	stride := 0
+	for _, attr := range l.Attributes {
+		stride += attr.size()
+	}
+	return stride
+}
+
+// returns the byte offset of an attribute inside a vertex
+func (l VertexLayout) Offset(name string) (int, error) {
+	offset := 0
+	for _, attr := range l.Attributes {
+		if attr.Name == name {
+			return offset, nil
+		}
+		offset += attr.size()
+	}
+	return 0, fmt.Errorf("vertex layout has no attribute %q", name)
+}
+
+// points the attributes of the program at the vertex buffer bound to
+// gl.ARRAY_BUFFER, recording them in the bound vertex array
+func (l VertexLayout) bind(program uint32) error {
+	stride := int32(l.Stride())
+	offset := 0
+
+	for _, attr := range l.Attributes {
+		if componentSizeGL(attr.Type) == 0 {
+			return fmt.Errorf("attribute %q has unsupported type 0x%x", attr.Name, attr.Type)
+		}
+
+		location := gl.GetAttribLocation(program, gl.Str(attr.Name+"\x00"))
+		if location < 0 && !attr.Optional {
+			return fmt.Errorf("attribute %q is not an input of the program", attr.Name)
+		}
+
+		if location >= 0 {
+			gl.EnableVertexAttribArray(uint32(location))
+			switch {
+			case attr.Type == gl.FLOAT || attr.Type == gl.HALF_FLOAT || attr.Normalized:
+				gl.VertexAttribPointer(uint32(location), attr.Components, attr.Type, attr.Normalized, stride, gl.PtrOffset(offset))
+			case attr.Type == gl.DOUBLE:
+				gl.VertexAttribLPointer(uint32(location), attr.Components, attr.Type, stride, gl.PtrOffset(offset))
+			default:
+				// integers reach the shader unconverted
+				gl.VertexAttribIPointer(uint32(location), attr.Components, attr.Type, stride, gl.PtrOffset(offset))
+			}
+		}
+		offset += attr.size()
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+func TestVertexLayoutMixedTypes(t *testing.T) {
+	layout := NewVertexLayout(
+		VertexAttribute{Name: "vert", Components: 3, Type: gl.FLOAT},
+		VertexAttribute{Name: "vertColor", Components: 4, Type: gl.UNSIGNED_BYTE, Normalized: true},
+		// optional attributes keep their place even when the program lacks them
+		VertexAttribute{Name: "vertNormal", Components: 3, Type: gl.SHORT, Optional: true},
+		VertexAttribute{Name: "skinAttr", Components: 2, Type: gl.DOUBLE},
+	)
+
+	if stride := layout.Stride(); stride != 12+4+6+16 {
+		t.Errorf("stride %d, want 38", stride)
+	}
+	for name, want := range map[string]int{"vert": 0, "vertColor": 12, "vertNormal": 16, "skinAttr": 22} {
+		if offset, err := layout.Offset(name); err != nil || offset != want {
+			t.Errorf("%s at offset %d (%v), want %d", name, offset, err, want)
+		}
+	}
+	if _, err := layout.Offset("vertTexCoord"); err == nil {
+		t.Error("offset of a missing attribute found")
+	}
+}
+
+func TestMeshLayoutMatchesVertexData(t *testing.T) {
+	mesh := Mesh{Positions: [][3]float32{{1, 2, 3}, {4, 5, 6}}}
+	if got, want := len(mesh.vertexData())*4, len(mesh.Positions)*meshLayout.Stride(); got != want {
+		t.Errorf("%d bytes of vertex data, want %d", got, want)
+	}
+	if offset, err := meshLayout.Offset("skinWeights"); err != nil || offset != 10*4 {
+		t.Errorf("skin weights at offset %d (%v), want 40", offset, err)
+	}
+}
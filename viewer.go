@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// environment variable naming the asset root when -assets is not given
+const assetsEnv = "TROLLHOUSE_ASSETS"
+
+// ViewerOptions are the files the viewer shows; relative paths are taken
+// from the asset root by resolve
+type ViewerOptions struct {
+	Mesh     string
+	Skeleton string
+	// clips played by the number keys, in order
+	Clips                 []string
+	Texture               string
+	VertexShader          string
+	FragmentShader        string
+	OverlayVertexShader   string
+	OverlayFragmentShader string
+}
+
+// defaultAssetRoot is the directory named by TROLLHOUSE_ASSETS, else the
+// directory of the executable when it holds the shaders and resources, so
+// the viewer starts from anywhere, else the working directory
+func defaultAssetRoot() string {
+	if root := os.Getenv(assetsEnv); root != "" {
+		return root
+	}
+	if executable, err := os.Executable(); err == nil {
+		dir := filepath.Dir(executable)
+		shaders, errShaders := os.Stat(filepath.Join(dir, "shaders"))
+		resources, errResources := os.Stat(filepath.Join(dir, "resources"))
+		if errShaders == nil && errResources == nil && shaders.IsDir() && resources.IsDir() {
+			return dir
+		}
+	}
+	return "."
+}
+
+// returns the path under the asset root, absolute paths are kept
+func assetPath(root, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// resolves every path of the options against the asset root
+func (o ViewerOptions) resolve(root string) ViewerOptions {
+	resolved := o
+	resolved.Mesh = assetPath(root, o.Mesh)
+	resolved.Skeleton = assetPath(root, o.Skeleton)
+	resolved.Texture = assetPath(root, o.Texture)
+	resolved.VertexShader = assetPath(root, o.VertexShader)
+	resolved.FragmentShader = assetPath(root, o.FragmentShader)
+	resolved.OverlayVertexShader = assetPath(root, o.OverlayVertexShader)
+	resolved.OverlayFragmentShader = assetPath(root, o.OverlayFragmentShader)
+	resolved.Clips = make([]string, len(o.Clips))
+	for i, clip := range o.Clips {
+		resolved.Clips[i] = assetPath(root, clip)
+	}
+	return resolved
+}
+
+// RunViewer opens a window playing the clips on the skinned mesh until it
+// is closed
+func RunViewer(opts ViewerOptions) error {
+	if err := glfw.Init(); err != nil {
+		return fmt.Errorf("failed to initialize glfw: %v", err)
+	}
+	defer glfw.Terminate()
+
+	name := filepath.Base(opts.Mesh)
+	window, err := newContext(windowWidth, windowHeight, name, true)
+	if err != nil {
+		return err
+	}
+	defer window.Destroy()
+
+	version := gl.GoStr(gl.GetString(gl.VERSION))
+	renderLog.Info("context created", "version", version)
+
+	// on HiDPI screens the framebuffer has more pixels than the window
+	width, height := window.GetFramebufferSize()
+
+	// Configure the shaders, meshes and textures
+	renderer, err := NewGLRenderer(opts.VertexShader, opts.FragmentShader, opts.Texture, width, height)
+	if err != nil {
+		return err
+	}
+	scene, err := NewScene(renderer, width, height, []string{opts.Mesh})
+	if err != nil {
+		return err
+	}
+	defer scene.Delete()
+
+	// Create animation tree
+	tree, constraints, err := LoadSkeleton(opts.Skeleton)
+	if err != nil {
+		return err
+	}
+	if len(tree.Nodes) > maxAnimationNodes {
+		return fmt.Errorf("animation tree has %d nodes, at most %d are supported", len(tree.Nodes), maxAnimationNodes)
+	}
+	if err := scene.checkSkin(tree); err != nil {
+		return err
+	}
+
+	window.SetFramebufferSizeCallback(func(w *glfw.Window, width, height int) {
+		renderLog.Debug("framebuffer resized", "width", width, "height", height)
+		scene.resize(width, height)
+	})
+	placement := &windowPlacement{}
+
+	// drag to orbit, middle drag to pan, scroll to zoom; C switches to flying
+	// with WASD, F frames the skeleton
+	camera := NewCamera(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0})
+	camera.bindInput(window)
+	frameSkeleton := false
+
+	// P dumps the pose to a JSON file, like the signals in poseDumpSignals
+	dumpPose := false
+	dumpSignals := make(chan os.Signal, 1)
+	if len(poseDumpSignals) > 0 {
+		signal.Notify(dumpSignals, poseDumpSignals...)
+	}
+
+	// B shows the skeleton, Z hides the parts behind the mesh, F11 toggles
+	// fullscreen
+	overlay, err := NewSkeletonOverlay(opts.OverlayVertexShader, opts.OverlayFragmentShader)
+	if err != nil {
+		return err
+	}
+	defer overlay.Delete()
+
+	// space pauses, left and right step frames, up and down step keyframes,
+	// 1 to 9 pick a clip and + and - change the speed
+	playback, err := NewPlayback(tree, opts.Clips)
+	if err != nil {
+		return err
+	}
+
+	// Tab edits the clip: the left button scrubs the timeline, [ and ] pick a
+	// node, T, R and G nudge its translation, rotation or scale with J and L,
+	// I and K, U and O along X, Y and Z, Insert and Delete add and remove
+	// keyframes and Ctrl+S saves the clip as clip.edited.saf
+	editor := NewKeyframeEditor(playback)
+	timeline, err := NewTimeline(opts.OverlayVertexShader, opts.OverlayFragmentShader)
+	if err != nil {
+		return err
+	}
+	defer timeline.Delete()
+	timeline.bindInput(window, playback)
+
+	window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		if action == glfw.Release {
+			return
+		}
+		// held arrows keep stepping
+		switch key {
+		case glfw.KeyRight:
+			playback.stepFrame(1)
+		case glfw.KeyLeft:
+			playback.stepFrame(-1)
+		case glfw.KeyUp:
+			playback.stepKeyframe(1)
+		case glfw.KeyDown:
+			playback.stepKeyframe(-1)
+		}
+		if action != glfw.Press {
+			return
+		}
+		if key == glfw.KeyTab {
+			editor.toggle()
+			timeline.Enabled = editor.Enabled
+			overlay.Enabled = overlay.Enabled || editor.Enabled
+			return
+		}
+		if editor.Enabled && editKey(editor, key, mods, len(tree.Nodes)) {
+			return
+		}
+
+		switch key {
+		case glfw.KeySpace:
+			playback.togglePause()
+		case glfw.KeyEqual, glfw.KeyKPAdd:
+			playback.changeSpeed(2.0)
+		case glfw.KeyMinus, glfw.KeyKPSubtract:
+			playback.changeSpeed(0.5)
+		case glfw.Key1, glfw.Key2, glfw.Key3, glfw.Key4, glfw.Key5, glfw.Key6, glfw.Key7, glfw.Key8, glfw.Key9:
+			playback.selectClip(int(key - glfw.Key1))
+		case glfw.KeyB:
+			overlay.toggle()
+		case glfw.KeyZ:
+			overlay.DepthTest = !overlay.DepthTest
+		case glfw.KeyP:
+			dumpPose = true
+		case glfw.KeyF11:
+			toggleFullscreen(w, placement)
+		case glfw.KeyC:
+			camera.toggleMode()
+		case glfw.KeyF:
+			frameSkeleton = true
+		}
+	})
+
+	assetsLog.Info("assets loaded", "nodes", len(tree.Nodes), "constraints", len(constraints),
+		"clips", playback.Names)
+
+	previousTime := glfw.GetTime()
+	title := ""
+
+	for !window.ShouldClose() {
+		// Update
+		time := glfw.GetTime()
+		elapsed := time - previousTime
+		previousTime = time
+
+		// Render
+		tree.resetTree()
+		playback.advance(elapsed)
+		if editor.Enabled {
+			// show the keys as they are, without the constraints
+			tree = playback.animate(tree)
+		} else {
+			tree = applyConstraints(playback.animate(tree), constraints, time)
+		}
+
+		if animationLog.Enabled(context.Background(), slog.LevelDebug) {
+			t, r, s := tree.getAnimation()
+			animationLog.Debug("pose", "time", time, "translation", t, "rotationY", r, "scale", s)
+		}
+
+		select {
+		case <-dumpSignals:
+			dumpPose = true
+		default:
+		}
+		if dumpPose {
+			dumpPose = false
+			filename := poseDumpName()
+			if err := DumpPose(filename, tree, time); err != nil {
+				animationLog.Error("pose dump failed", "err", err)
+			} else {
+				animationLog.Info("pose dumped", "file", filename)
+			}
+		}
+
+		if frameSkeleton {
+			frameSkeleton = false
+			camera.frame(tree, mgl32.DegToRad(fieldOfView), scene.aspect())
+		}
+		camera.update(window, elapsed)
+		scene.Camera = camera.view()
+
+		overlay.Selected = -1
+		if editor.Enabled {
+			overlay.Selected = editor.Node
+		}
+
+		scene.draw(tree)
+		overlay.draw(scene.frame(tree))
+		timeline.draw(playback, editor.currentKey())
+
+		status := name + " - " + playback.status()
+		if editor.Enabled {
+			status += " - " + editor.status(tree)
+		}
+		if status != title {
+			title = status
+			window.SetTitle(title)
+		}
+
+		// Maintenance
+		window.SwapBuffers()
+		glfw.PollEvents()
+	}
+	return nil
+}
+
+// handles the keys of the keyframe editor, returning false for the keys it
+// leaves to the viewer
+func editKey(editor *KeyframeEditor, key glfw.Key, mods glfw.ModifierKey, nodes int) bool {
+	steps := float32(1.0)
+	if mods&glfw.ModShift != 0 {
+		steps = 5.0
+	}
+
+	nudges := map[glfw.Key]struct {
+		axis  int
+		steps float32
+	}{
+		glfw.KeyJ: {0, -steps}, glfw.KeyL: {0, steps},
+		glfw.KeyK: {1, -steps}, glfw.KeyI: {1, steps},
+		glfw.KeyU: {2, -steps}, glfw.KeyO: {2, steps},
+	}
+	if nudge, ok := nudges[key]; ok {
+		editor.nudge(nudge.axis, nudge.steps)
+		return true
+	}
+
+	switch key {
+	case glfw.KeyLeftBracket:
+		editor.selectNode(-1, nodes)
+	case glfw.KeyRightBracket:
+		editor.selectNode(1, nodes)
+	case glfw.KeyT:
+		editor.Channel = EditTranslation
+	case glfw.KeyR:
+		editor.Channel = EditRotation
+	case glfw.KeyG:
+		editor.Channel = EditScale
+	case glfw.KeyInsert:
+		editor.insertKey()
+	case glfw.KeyDelete, glfw.KeyBackspace:
+		if err := editor.deleteKey(); err != nil {
+			animationLog.Warn("keyframe not deleted", "err", err)
+		}
+	case glfw.KeyS:
+		if mods&glfw.ModControl == 0 {
+			return false
+		}
+		if filename, err := editor.save(); err != nil {
+			assetsLog.Error("clip not saved", "err", err)
+		} else {
+			assetsLog.Info("clip saved", "file", filename)
+		}
+	default:
+		return false
+	}
+	return true
+}
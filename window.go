@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// windowPlacement remembers where a window was before going fullscreen, so
+// toggling back restores it
+type windowPlacement struct {
+	x, y          int
+	width, height int
+}
+
+// switches the window between fullscreen on the primary monitor and its
+// previous windowed placement; the framebuffer size callback follows
+func toggleFullscreen(window *glfw.Window, placement *windowPlacement) {
+	if window.GetMonitor() != nil {
+		window.SetMonitor(nil, (*placement).x, (*placement).y, (*placement).width, (*placement).height, 0)
+		return
+	}
+
+	monitor := glfw.GetPrimaryMonitor()
+	if monitor == nil {
+		return
+	}
+	mode := monitor.GetVideoMode()
+	if mode == nil {
+		return
+	}
+
+	(*placement).x, (*placement).y = window.GetPos()
+	(*placement).width, (*placement).height = window.GetSize()
+	window.SetMonitor(monitor, 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+}